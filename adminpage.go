@@ -0,0 +1,143 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"mobile-name-lookup/db"
+)
+
+// adminRecordsPageSize is how many records each page of /admin/records shows
+const adminRecordsPageSize = 50
+
+// adminRecordsTemplate lists cached records with the form page's styling
+const adminRecordsTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Cached records - {{.Brand.Title}}</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <meta name="robots" content="noindex">
+    <link rel="icon" href="{{.BasePath}}/static/favicon.svg" type="image/svg+xml">
+    <link rel="stylesheet" href="{{.BasePath}}/static/style.css?v={{.AssetVersion}}">
+    <style>
+        :root {
+            --brand-color: {{.Brand.Color}};
+            --brand-hover-color: {{.Brand.HoverColor}};
+        }
+    </style>
+</head>
+<body>
+    <div class="container wide">
+        <h1>Cached records</h1>
+        <form method="GET" action="{{.BasePath}}/admin/records" class="search">
+            <input type="search" name="q" value="{{.Search}}" placeholder="Part of a number or name" aria-label="Search">
+            <button type="submit">Search</button>
+        </form>
+        {{if .Error}}
+        <div class="error">{{.Error}}</div>
+        {{else if .Records}}
+        <table class="batch-results">
+            <tr><th>Number</th><th>Name</th><th>Entered as</th><th>Updated</th></tr>
+            {{range .Records}}
+            <tr>
+                <td>{{.Mobile}}</td>
+                <td>{{.Name}}</td>
+                <td>{{.RawMobile}}</td>
+                <td>{{.UpdatedAt.UTC.Format "2006-01-02 15:04"}}</td>
+            </tr>
+            {{end}}
+        </table>
+        {{else}}
+        <div class="result">No records{{if .Search}} match &ldquo;{{.Search}}&rdquo;{{end}}</div>
+        {{end}}
+        <div class="pagination">
+            {{with .PrevURL}}<a href="{{.}}">&larr; Previous</a>{{end}}
+            <span>Page {{.Page}}</span>
+            {{with .NextURL}}<a href="{{.}}">Next &rarr;</a>{{end}}
+        </div>
+    </div>
+</body>
+</html>
+`
+
+// adminRecordsData is passed to adminRecordsTemplate
+type adminRecordsData struct {
+	BasePath     string
+	Brand        Brand
+	AssetVersion string
+
+	Search  string
+	Records []*db.MobileRecord
+	Page    int
+	PrevURL string
+	NextURL string
+	Error   string
+}
+
+// adminRecordsHandler serves GET /admin/records, a browsable list of the
+// cached records, most recently updated first. ?q= narrows it to records
+// whose number or name contains the text and ?page= picks the page.
+func adminRecordsHandler(database Store, routePrefix string, brand Brand) http.HandlerFunc {
+	tmpl := template.Must(template.New("admin-records").Parse(adminRecordsTemplate))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		search := strings.TrimSpace(r.URL.Query().Get("q"))
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		data := adminRecordsData{
+			BasePath:     routePrefix,
+			Brand:        brand,
+			AssetVersion: staticVersion,
+			Search:       search,
+			Page:         page,
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		// One extra record tells whether there is a next page
+		records, err := database.ListMobileRecords(r.Context(), search, adminRecordsPageSize+1, (page-1)*adminRecordsPageSize)
+		if err != nil {
+			logger.WithError(err).Error("Failed to list records")
+			lerr := databaseError(err)
+			data.Error = lerr.Message
+			renderTemplate(&statusWriter{ResponseWriter: w, status: lerr.Status}, r, tmpl, data)
+			return
+		}
+		if len(records) > adminRecordsPageSize {
+			records = records[:adminRecordsPageSize]
+			data.NextURL = adminRecordsURL(routePrefix, search, page+1)
+		}
+		if page > 1 {
+			data.PrevURL = adminRecordsURL(routePrefix, search, page-1)
+		}
+		data.Records = records
+
+		renderTemplate(w, r, tmpl, data)
+	}
+}
+
+// adminRecordsURL links to page of the records matching search
+func adminRecordsURL(routePrefix, search string, page int) string {
+	query := url.Values{}
+	if search != "" {
+		query.Set("q", search)
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if len(query) == 0 {
+		return routePrefix + "/admin/records"
+	}
+	return routePrefix + "/admin/records?" + query.Encode()
+}
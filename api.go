@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mobile-name-lookup/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// parsePagination reads the limit and offset query parameters
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultPageSize, 0
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// recordsJSON converts records to the JSON shape used by the API. The raw
+// input is only shown on admin routes, and only for records that have one.
+func recordsJSON(records []*db.MobileRecord, admin bool) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		result := map[string]interface{}{
+			"mobile":     record.Mobile,
+			"name":       record.Name,
+			"updated_at": record.UpdatedAt,
+		}
+		if admin && record.RawMobile != "" {
+			result["raw_mobile"] = record.RawMobile
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// reverseLookupHandler serves GET /api/v1/reverse?name=...[&match=prefix]
+// listing cached numbers whose name matches. Names aren't unique, so the
+// result is always a paginated array.
+func reverseLookupHandler(database Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "name is required")
+			return
+		}
+
+		limit, offset, err := parsePagination(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		records, err := database.FindByName(r.Context(), name, r.URL.Query().Get("match") == "prefix", limit, offset)
+		if err != nil {
+			logger.WithError(err).Error("Failed to query records by name")
+			writeDatabaseError(w, err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"results": recordsJSON(records, false),
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
+
+var suffixPattern = regexp.MustCompile(`^\d{4}$`)
+
+// suffixSearchHandler serves GET /api/v1/search/suffix?d=1234 for support
+// agents who only have the last 4 digits of a number
+func suffixSearchHandler(database Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		suffix := r.URL.Query().Get("d")
+		if !suffixPattern.MatchString(suffix) {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "d must be exactly 4 digits")
+			return
+		}
+
+		limit, _, err := parsePagination(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		records, err := database.SearchBySuffix(r.Context(), suffix, limit)
+		if err != nil {
+			logger.WithError(err).Error("Failed to search records by suffix")
+			writeDatabaseError(w, err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"results": recordsJSON(records, true),
+			"limit":   limit,
+		})
+	}
+}
+
+// recentLookupsHandler serves GET /api/v1/recent?limit=... for admins, the
+// latest provider responses across all numbers for a live dashboard.
+// Numbers are masked unless LOG_PII is on, like in the logs, with the hash
+// to tell them apart; names are never included.
+func recentLookupsHandler(database Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		limit, _, err := parsePagination(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		entries, err := database.RecentLookups(r.Context(), limit)
+		if err != nil {
+			logger.WithError(err).Error("Failed to list recent lookups")
+			writeDatabaseError(w, err)
+			return
+		}
+
+		results := make([]map[string]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			results = append(results, map[string]interface{}{
+				"mobile":      redactMobile(entry.Mobile),
+				"mobile_hash": hashMobile(entry.Mobile),
+				"status":      entry.Status,
+				"provider":    entry.Provider,
+				"status_code": entry.StatusCode,
+				"latency_ms":  entry.LatencyMs,
+				"created_at":  entry.CreatedAt,
+			})
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"results": results,
+			"limit":   limit,
+		})
+	}
+}
+
+// overridesHandler serves /api/v1/overrides for admins:
+//
+//	GET    ?mobile=...                      read the override
+//	PUT    {"mobile": "...", "name": "..."} set or replace it
+//	DELETE ?mobile=...                      clear it
+func overridesHandler(database Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodDelete:
+			mobile, err := cleanPhoneNumber(r.URL.Query().Get("mobile"))
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, mobileErrorCode(err), fmt.Sprintf("Invalid mobile number: %v", err))
+				return
+			}
+
+			if r.Method == http.MethodDelete {
+				deleted, err := database.DeleteOverride(r.Context(), mobile)
+				if err != nil {
+					logger.WithError(err).Error("Failed to delete override")
+					writeDatabaseError(w, err)
+					return
+				}
+				logger.WithFields(mobileLogFields(mobile)).Info("Override cleared")
+				auditAdminAction(database, r, auditOverrideDeleted, mobile, "")
+				respondWithJSON(w, http.StatusOK, map[string]interface{}{
+					"mobile":  mobile,
+					"deleted": deleted,
+				})
+				return
+			}
+
+			override, err := database.GetOverride(r.Context(), mobile)
+			if err != nil {
+				logger.WithError(err).Error("Failed to query overrides")
+				writeDatabaseError(w, err)
+				return
+			}
+			if override == nil {
+				writeJSONError(w, http.StatusNotFound, errCodeNotFound, "No override set for this number")
+				return
+			}
+			respondWithJSON(w, http.StatusOK, map[string]interface{}{
+				"mobile":     override.Mobile,
+				"name":       override.Name,
+				"updated_at": override.UpdatedAt,
+			})
+
+		case http.MethodPut, http.MethodPost:
+			var body struct {
+				Mobile string `json:"mobile"`
+				Name   string `json:"name"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				if bodyTooLarge(err) {
+					respondBodyTooLarge(w, r)
+					return
+				}
+				writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid JSON body")
+				return
+			}
+
+			mobile, err := cleanPhoneNumber(body.Mobile)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, mobileErrorCode(err), fmt.Sprintf("Invalid mobile number: %v", err))
+				return
+			}
+			name := strings.TrimSpace(body.Name)
+			if name == "" {
+				writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "name is required")
+				return
+			}
+
+			if err := database.SetOverride(r.Context(), mobile, name); err != nil {
+				logger.WithError(err).Error("Failed to save override")
+				writeDatabaseError(w, err)
+				return
+			}
+			logger.WithFields(mobileLogFields(mobile)).Info("Override set")
+			auditAdminAction(database, r, auditOverrideSet, mobile, "")
+			respondWithJSON(w, http.StatusOK, map[string]interface{}{
+				"mobile": mobile,
+				"name":   name,
+			})
+
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+// cachePurgeHandler serves POST /api/v1/cache/purge?older_than=720h for
+// admins, deleting cached records not updated within the given duration
+func cachePurgeHandler(database Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		olderThan, err := time.ParseDuration(r.URL.Query().Get("older_than"))
+		if err != nil || olderThan <= 0 {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "older_than must be a positive duration, e.g. 720h")
+			return
+		}
+
+		cutoff := time.Now().Add(-olderThan)
+		deleted, err := database.DeleteStaleRecords(r.Context(), cutoff)
+		if err != nil {
+			logger.WithError(err).Error("Failed to purge stale records")
+			writeDatabaseError(w, err)
+			return
+		}
+
+		logger.WithFields(logrus.Fields{
+			"older_than": olderThan.String(),
+			"deleted":    deleted,
+			"ip":         r.RemoteAddr,
+		}).Warn("Purged stale cache records")
+		auditAdminAction(database, r, auditCachePurged, "", "older_than="+olderThan.String())
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"deleted":    deleted,
+			"older_than": olderThan.String(),
+			"cutoff":     cutoff.UTC(),
+		})
+	}
+}
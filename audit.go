@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"mobile-name-lookup/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Admin actions recorded in the audit trail
+const (
+	auditOverrideSet      = "override_set"
+	auditOverrideDeleted  = "override_deleted"
+	auditCachePurged      = "cache_purged"
+	auditBackfillStarted  = "backfill_started"
+	auditBackfillStopped  = "backfill_cancelled"
+	auditImported         = "records_imported"
+	auditRecordReverified = "record_reverified"
+)
+
+// adminKeyID identifies an API key in the audit trail without storing it.
+// Keys are long random strings, so an unkeyed hash is enough to keep them
+// out of the table while still telling admins apart.
+func adminKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// auditAdminAction records who performed an admin mutation. A failure to
+// write the entry is logged but does not fail the request, since the
+// mutation has already happened by the time it is recorded. For the same
+// reason it is written even if the request's deadline has passed.
+func auditAdminAction(store Store, r *http.Request, action, mobile, detail string) {
+	entry := &db.AdminAuditEntry{
+		KeyID:    adminKeyID(r.Header.Get("X-API-Key")),
+		Action:   action,
+		Mobile:   mobile,
+		Detail:   detail,
+		ClientIP: remoteIP(r),
+	}
+	if err := store.SaveAdminAudit(context.WithoutCancel(r.Context()), entry); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"action": action,
+			"key_id": entry.KeyID,
+		}).Error("Failed to save admin audit entry")
+	}
+}
+
+// adminAuditHandler serves GET /api/v1/audit for admins, listing recorded
+// admin actions newest first
+func adminAuditHandler(database Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		limit, offset, err := parsePagination(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+
+		entries, err := database.ListAdminAudit(r.Context(), limit, offset)
+		if err != nil {
+			logger.WithError(err).Error("Failed to list admin audit entries")
+			writeDatabaseError(w, err)
+			return
+		}
+
+		results := make([]map[string]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			results = append(results, map[string]interface{}{
+				"key_id":     entry.KeyID,
+				"action":     entry.Action,
+				"mobile":     entry.Mobile,
+				"detail":     entry.Detail,
+				"client_ip":  entry.ClientIP,
+				"created_at": entry.CreatedAt,
+			})
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"results": results,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parseAPIKeys splits a comma-separated list of API keys, dropping blanks
+func parseAPIKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// validAPIKey reports whether key matches one of the allowed keys. Every
+// candidate is compared in constant time so the response time does not
+// reveal how much of a key matched or which key was close.
+func validAPIKey(key string, allowed []string) bool {
+	if key == "" {
+		return false
+	}
+
+	match := 0
+	for _, candidate := range allowed {
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(candidate))
+	}
+	return match == 1
+}
+
+// Middleware requiring a valid X-API-Key header
+func apiKeyMiddleware(next http.HandlerFunc, keys []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validAPIKey(r.Header.Get("X-API-Key"), keys) {
+			logger.WithFields(logrus.Fields{
+				"ip":     r.RemoteAddr,
+				"path":   r.URL.Path,
+				"status": "unauthorized",
+			}).Warn("Missing or invalid API key")
+
+			if isAPIRequest(r) {
+				respondWithAPIError(w, r, http.StatusUnauthorized, errCodeUnauthorized, "Missing or invalid API key")
+			} else {
+				http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+			}
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Middleware requiring HTTP basic auth whose password is one of keys, for
+// admin pages opened in a browser, which can't send X-API-Key. The
+// username is ignored.
+func basicAuthMiddleware(next http.HandlerFunc, keys []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || !validAPIKey(password, keys) {
+			if ok {
+				logger.WithFields(logrus.Fields{
+					"ip":     r.RemoteAddr,
+					"path":   r.URL.Path,
+					"status": "unauthorized",
+				}).Warn("Invalid admin password")
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
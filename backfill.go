@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// backfillPageSize is how many unresolved numbers are fetched at a time
+const backfillPageSize = 100
+
+// Backfill states reported in the stats endpoint
+const (
+	backfillIdle      = "idle"
+	backfillRunning   = "running"
+	backfillDone      = "done"
+	backfillCancelled = "cancelled"
+	backfillFailed    = "failed"
+)
+
+// backfillJob re-queries the provider for numbers that were looked up but
+// never resolved to a name, caching the ones that now resolve. At most one
+// run is in progress at a time; it runs in the background until it has
+// walked every such number or is cancelled.
+type backfillJob struct {
+	service     *lookupService
+	store       Store
+	concurrency int
+	limiter     *rate.Limiter
+	// timeout bounds each lookup like REQUEST_TIMEOUT bounds a request
+	timeout time.Duration
+
+	mu         sync.Mutex
+	cancel     context.CancelFunc
+	state      string
+	startedAt  time.Time
+	finishedAt time.Time
+	lastError  string
+
+	scanned  atomic.Int64
+	resolved atomic.Int64
+	failed   atomic.Int64
+}
+
+// newBackfillJob creates an idle job making at most perMinute provider
+// lookups per minute, concurrency at a time
+func newBackfillJob(service *lookupService, store Store, concurrency, perMinute int, timeout time.Duration) *backfillJob {
+	return &backfillJob{
+		service:     service,
+		store:       store,
+		concurrency: concurrency,
+		limiter:     rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), 1),
+		timeout:     timeout,
+		state:       backfillIdle,
+	}
+}
+
+// Start begins a run in the background. It returns false without starting
+// one when a run is already in progress.
+func (j *backfillJob) Start() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.state == backfillRunning {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.state, j.startedAt, j.finishedAt, j.lastError = backfillRunning, time.Now(), time.Time{}, ""
+	j.scanned.Store(0)
+	j.resolved.Store(0)
+	j.failed.Store(0)
+
+	go j.run(ctx)
+	return true
+}
+
+// Cancel stops the run in progress, reporting whether there was one.
+// Lookups already in flight finish or are cut short by their context.
+func (j *backfillJob) Cancel() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.state != backfillRunning {
+		return false
+	}
+	j.cancel()
+	return true
+}
+
+// run walks the unresolved numbers page by page until there are none left
+// or ctx is cancelled
+func (j *backfillJob) run(ctx context.Context) {
+	var runErr error
+	after := ""
+	for ctx.Err() == nil {
+		mobiles, err := j.store.UnresolvedMobiles(ctx, after, backfillPageSize)
+		if err != nil {
+			// A query cut short by Cancel is a cancellation, not a failure
+			if ctx.Err() == nil {
+				runErr = err
+			}
+			break
+		}
+		if len(mobiles) == 0 {
+			break
+		}
+		after = mobiles[len(mobiles)-1]
+
+		runBounded(ctx, len(mobiles), j.concurrency, func(i int) {
+			if err := j.limiter.Wait(ctx); err != nil {
+				return
+			}
+			j.lookup(ctx, mobiles[i])
+		})
+	}
+
+	j.finish(ctx, runErr)
+}
+
+// lookup re-resolves one number through the normal lookup path, so an
+// override or a name cached since the last attempt still wins over a
+// provider call
+func (j *backfillJob) lookup(ctx context.Context, mobile string) {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+
+	j.scanned.Add(1)
+	outcome, lerr := j.service.Resolve(ctx, lookupInput{Mobile: mobile, RemoteAddr: "backfill"})
+	switch {
+	case lerr != nil:
+		j.failed.Add(1)
+	case !outcome.notFound():
+		j.resolved.Add(1)
+	}
+}
+
+// finish records how the run ended
+func (j *backfillJob) finish(ctx context.Context, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finishedAt = time.Now()
+	switch {
+	case err != nil:
+		j.state, j.lastError = backfillFailed, err.Error()
+	case ctx.Err() != nil:
+		j.state = backfillCancelled
+	default:
+		j.state = backfillDone
+	}
+	// Release the context now that the run no longer needs it
+	j.cancel()
+
+	fields := logrus.Fields{
+		"state":    j.state,
+		"scanned":  j.scanned.Load(),
+		"resolved": j.resolved.Load(),
+		"failed":   j.failed.Load(),
+	}
+	if err != nil {
+		logger.WithError(err).WithFields(fields).Error("Backfill failed")
+		return
+	}
+	logger.WithFields(fields).Info("Backfill finished")
+}
+
+// Snapshot reports the state and progress of the current or last run
+func (j *backfillJob) Snapshot() map[string]interface{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snapshot := map[string]interface{}{
+		"state":    j.state,
+		"scanned":  j.scanned.Load(),
+		"resolved": j.resolved.Load(),
+		"failed":   j.failed.Load(),
+	}
+	if !j.startedAt.IsZero() {
+		snapshot["started_at"] = j.startedAt.UTC()
+	}
+	if !j.finishedAt.IsZero() {
+		snapshot["finished_at"] = j.finishedAt.UTC()
+	}
+	if j.lastError != "" {
+		snapshot["error"] = j.lastError
+	}
+	return snapshot
+}
+
+// backfillHandler serves /api/v1/backfill for admins: POST starts a run and
+// DELETE cancels it. Progress is reported under "backfill" in the stats.
+func backfillHandler(job *backfillJob) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			if !job.Start() {
+				writeJSONError(w, http.StatusConflict, errCodeConflict, "A backfill is already running")
+				return
+			}
+			logger.WithField("ip", r.RemoteAddr).Warn("Backfill started by admin")
+			auditAdminAction(job.store, r, auditBackfillStarted, "", "")
+			respondWithJSON(w, http.StatusAccepted, job.Snapshot())
+		case http.MethodDelete:
+			if !job.Cancel() {
+				writeJSONError(w, http.StatusConflict, errCodeConflict, "No backfill is running")
+				return
+			}
+			logger.WithField("ip", r.RemoteAddr).Warn("Backfill cancelled by admin")
+			auditAdminAction(job.store, r, auditBackfillStopped, "", "")
+			respondWithJSON(w, http.StatusOK, job.Snapshot())
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const maxBatchSize = 100
+
+// runBounded calls fn for every index in [0, n) with at most limit calls in
+// flight. Once ctx is done no further calls are started; calls already
+// running are expected to watch ctx themselves.
+func runBounded(ctx context.Context, n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// Both cases can be ready at once; never start work after
+		// cancellation
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// batchItem is the result for one input of a batch lookup; exactly one of
+// Outcome and Err is set
+type batchItem struct {
+	Input   string
+	Outcome *lookupOutcome
+	Err     *lookupError
+}
+
+// ResolveBatch resolves mobiles concurrently, at most s.batchConcurrency at
+// a time, returning results in input order. Inputs not started before ctx
+// is done are reported as timed out.
+func (s *lookupService) ResolveBatch(ctx context.Context, mobiles []string, dryRun bool, remoteAddr string) []batchItem {
+	items := make([]batchItem, len(mobiles))
+	runBounded(ctx, len(mobiles), s.batchConcurrency, func(i int) {
+		outcome, lerr := s.Resolve(ctx, lookupInput{
+			Mobile:     mobiles[i],
+			DryRun:     dryRun,
+			RemoteAddr: remoteAddr,
+		})
+		items[i] = batchItem{Input: mobiles[i], Outcome: outcome, Err: lerr}
+	})
+
+	for i := range items {
+		if items[i].Outcome == nil && items[i].Err == nil {
+			items[i] = batchItem{
+				Input: mobiles[i],
+				Err:   &lookupError{http.StatusGatewayTimeout, errCodeTimeout, "Lookup not attempted before the request deadline"},
+			}
+		}
+	}
+	return items
+}
+
+// Statuses of batch items. A number without a name was still looked up, so
+// it is not a failure.
+const (
+	batchStatusOK       = "ok"
+	batchStatusNotFound = "not_found"
+	batchStatusFailed   = "failed"
+)
+
+// status classifies the item for the batch summary
+func (item batchItem) status() string {
+	switch {
+	case item.Err != nil:
+		return batchStatusFailed
+	case item.Outcome.notFound() && item.Outcome.Placeholder == "":
+		return batchStatusNotFound
+	default:
+		return batchStatusOK
+	}
+}
+
+// batchFailure returns the error to answer a batch with when every item
+// failed on our or the provider's side, such as with the database down, or
+// for a spent daily quota. Batches where anything was answered, or where
+// items only failed for bad input, get 200 with the failures per item, so
+// nil is returned.
+func batchFailure(items []batchItem) *lookupError {
+	for _, item := range items {
+		if item.Err == nil || item.Err.Status < http.StatusInternalServerError && item.Err.Status != http.StatusTooManyRequests {
+			return nil
+		}
+	}
+	for _, item := range items[1:] {
+		if item.Err.Status != items[0].Err.Status {
+			return &lookupError{http.StatusBadGateway, errCodeUpstream, "Every lookup in the batch failed"}
+		}
+	}
+	return &lookupError{items[0].Err.Status, items[0].Err.Code, "Every lookup in the batch failed: " + items[0].Err.Message}
+}
+
+// json is the JSON API shape of a batch result
+func (item batchItem) json() map[string]interface{} {
+	result := map[string]interface{}{"input": item.Input, "status": item.status()}
+	switch {
+	case item.Err != nil:
+		result["error"] = map[string]string{"code": item.Err.Code, "message": item.Err.Message}
+	case item.Outcome.notFound() && item.Outcome.Placeholder == "":
+		result["mobile"] = item.Outcome.Mobile
+		result["error"] = map[string]string{"code": errCodeNotFound, "message": "No name found for this number"}
+	default:
+		result["mobile"] = item.Outcome.Mobile
+		result["name"] = item.Outcome.displayName()
+		result["source"] = item.Outcome.Source
+		if item.Outcome.Source != sourceDryRun {
+			result["name_found"] = !item.Outcome.notFound()
+		}
+	}
+	return result
+}
+
+// batchRow is one row of the HTML results table
+type batchRow struct {
+	Input  string
+	Mobile string
+	Name   string
+	Source string
+	Error  string
+}
+
+// row is the HTML table shape of a batch result
+func (item batchItem) row() batchRow {
+	row := batchRow{Input: item.Input}
+	switch {
+	case item.Err != nil:
+		row.Error = item.Err.Message
+	case item.Outcome.Source == sourceDryRun:
+		row.Mobile, row.Source, row.Error = item.Outcome.Mobile, item.Outcome.Source, dryRunMessage
+	case item.Outcome.notFound() && item.Outcome.Placeholder == "":
+		row.Mobile, row.Source, row.Error = item.Outcome.Mobile, item.Outcome.Source, "No name found for this number"
+	default:
+		row.Mobile, row.Name, row.Source = item.Outcome.Mobile, item.Outcome.displayName(), item.Outcome.Source
+	}
+	return row
+}
+
+// splitMobileLines splits textarea input into one number per non-blank line
+func splitMobileLines(text string) []string {
+	var mobiles []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			mobiles = append(mobiles, line)
+		}
+	}
+	return mobiles
+}
+
+// batchLookupHandler serves POST /api/v1/batch with a body of
+// {"mobiles": [...]}. Numbers are resolved concurrently and results are
+// returned in input order, each with its own status, along with a summary
+// counting each status. The response is 200 unless every lookup failed on
+// the server side or past the daily quota (see batchFailure).
+func batchLookupHandler(service *lookupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		var body struct {
+			Mobiles []string `json:"mobiles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			if bodyTooLarge(err) {
+				respondBodyTooLarge(w, r)
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid JSON body")
+			return
+		}
+		if len(body.Mobiles) == 0 {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "mobiles must list at least one number")
+			return
+		}
+		if len(body.Mobiles) > maxBatchSize {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("mobiles must list at most %d numbers", maxBatchSize))
+			return
+		}
+
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryrun"))
+
+		items := service.ResolveBatch(r.Context(), body.Mobiles, dryRun, r.RemoteAddr)
+		results := make([]map[string]interface{}, len(items))
+		summary := map[string]int{batchStatusOK: 0, batchStatusNotFound: 0, batchStatusFailed: 0}
+		for i, item := range items {
+			results[i] = item.json()
+			summary[item.status()]++
+		}
+		response := map[string]interface{}{"summary": summary, "results": results}
+
+		status := http.StatusOK
+		if lerr := batchFailure(items); lerr != nil {
+			logger.WithField("numbers", len(items)).Warn("Every lookup in the batch failed")
+			status = lerr.Status
+			response["error"] = map[string]string{"code": lerr.Code, "message": lerr.Message}
+		}
+		respondWithJSON(w, status, response)
+	}
+}
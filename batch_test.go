@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+func TestRunBoundedLimitsConcurrency(t *testing.T) {
+	tests := []struct {
+		n, limit int
+	}{
+		{n: 20, limit: 1},
+		{n: 20, limit: 4},
+		{n: 3, limit: 10},
+		{n: 0, limit: 4},
+	}
+	for _, tt := range tests {
+		var inFlight, peak atomic.Int32
+		var mu sync.Mutex
+		seen := make(map[int]int)
+
+		runBounded(context.Background(), tt.n, tt.limit, func(i int) {
+			now := inFlight.Add(1)
+			for {
+				old := peak.Load()
+				if now <= old || peak.CompareAndSwap(old, now) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			inFlight.Add(-1)
+
+			mu.Lock()
+			seen[i]++
+			mu.Unlock()
+		})
+
+		if got := int(peak.Load()); got > tt.limit {
+			t.Errorf("n=%d limit=%d: %d calls in flight", tt.n, tt.limit, got)
+		}
+		if len(seen) != tt.n {
+			t.Errorf("n=%d limit=%d: called for %d indexes", tt.n, tt.limit, len(seen))
+		}
+		for i, calls := range seen {
+			if calls != 1 {
+				t.Errorf("n=%d limit=%d: index %d called %d times", tt.n, tt.limit, i, calls)
+			}
+		}
+	}
+}
+
+func TestRunBoundedStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+
+	runBounded(ctx, 50, 2, func(i int) {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		<-ctx.Done()
+	})
+
+	// Both slots were taken when ctx was cancelled, so nothing else started
+	if got := started.Load(); got != 2 {
+		t.Errorf("started %d calls, want 2", got)
+	}
+}
+
+func TestBatchItemStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		item batchItem
+		want string
+	}{
+		{"found", batchItem{Outcome: &lookupOutcome{Name: "Ravi Kumar", Source: sourceAPI}}, batchStatusOK},
+		{"not found", batchItem{Outcome: &lookupOutcome{Source: sourceAPI}}, batchStatusNotFound},
+		{"not found with placeholder", batchItem{Outcome: &lookupOutcome{Source: sourceAPI, Placeholder: "Unknown"}}, batchStatusOK},
+		{"dry run", batchItem{Outcome: &lookupOutcome{Source: sourceDryRun}}, batchStatusOK},
+		{"failed", batchItem{Err: &lookupError{http.StatusBadRequest, errCodeMobileLength, "bad"}}, batchStatusFailed},
+	}
+	for _, tt := range tests {
+		if got := tt.item.status(); got != tt.want {
+			t.Errorf("%s: status = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBatchFailure(t *testing.T) {
+	ok := batchItem{Outcome: &lookupOutcome{Name: "Ravi Kumar", Source: sourceAPI}}
+	notFound := batchItem{Outcome: &lookupOutcome{Source: sourceAPI}}
+	invalid := batchItem{Err: &lookupError{http.StatusBadRequest, errCodeMobileLength, "bad length"}}
+	upstream := batchItem{Err: &lookupError{http.StatusInternalServerError, errCodeUpstream, "unavailable"}}
+	database := batchItem{Err: &lookupError{http.StatusInternalServerError, errCodeDatabase, "database"}}
+	timeout := batchItem{Err: &lookupError{http.StatusGatewayTimeout, errCodeTimeout, "timed out"}}
+	quota := batchItem{Err: &lookupError{http.StatusTooManyRequests, errCodeQuotaExceeded, "quota"}}
+
+	tests := []struct {
+		name       string
+		items      []batchItem
+		wantStatus int // 0 for no failure
+		wantCode   string
+	}{
+		{name: "all answered", items: []batchItem{ok, notFound}},
+		{name: "partly failed", items: []batchItem{ok, upstream, timeout}},
+		{name: "only bad input", items: []batchItem{invalid, invalid}},
+		{name: "bad input and server errors", items: []batchItem{invalid, upstream}},
+		{name: "all upstream", items: []batchItem{upstream, upstream}, wantStatus: http.StatusInternalServerError, wantCode: errCodeUpstream},
+		{name: "all quota", items: []batchItem{quota, quota}, wantStatus: http.StatusTooManyRequests, wantCode: errCodeQuotaExceeded},
+		{name: "same status, different codes", items: []batchItem{upstream, database}, wantStatus: http.StatusInternalServerError, wantCode: errCodeUpstream},
+		{name: "mixed server errors", items: []batchItem{database, timeout}, wantStatus: http.StatusBadGateway, wantCode: errCodeUpstream},
+		{name: "quota and timeout", items: []batchItem{quota, timeout}, wantStatus: http.StatusBadGateway, wantCode: errCodeUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lerr := batchFailure(tt.items)
+			if tt.wantStatus == 0 {
+				if lerr != nil {
+					t.Errorf("batchFailure = %+v, want nil", lerr)
+				}
+				return
+			}
+			if lerr == nil || lerr.Status != tt.wantStatus || lerr.Code != tt.wantCode {
+				t.Errorf("batchFailure = %+v, want %d %s", lerr, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestResolveBatchMarksUnstartedAsTimedOut(t *testing.T) {
+	service := newTestService(db.NewMemoryStore(), &fakeProvider{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	items := service.ResolveBatch(ctx, []string{"9876501234", "9123456780"}, false, "203.0.113.7:4000")
+	for i, item := range items {
+		if item.Err == nil || item.Err.Code != errCodeTimeout || item.Input == "" {
+			t.Errorf("item %d = %+v, want a timeout for its input", i, item)
+		}
+	}
+}
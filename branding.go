@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Defaults matching the original look of the page
+const (
+	defaultBrandTitle = "Mobile Name Lookup"
+	defaultBrandColor = "#4CAF50"
+)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Brand customizes the web page per deployment
+type Brand struct {
+	Title string
+	// Color is the accent color of the buttons, as #rrggbb
+	Color string
+	// HoverColor is Color slightly darkened for hovered buttons
+	HoverColor string
+	// LogoURL, if set, is shown above the title
+	LogoURL string
+}
+
+// newBrand builds a Brand, deriving the hover color from color, which must
+// be a #rrggbb hex color
+func newBrand(title, color, logoURL string) (Brand, error) {
+	if !hexColorPattern.MatchString(color) {
+		return Brand{}, fmt.Errorf("BRAND_COLOR must be a hex color like #4CAF50, got %q", color)
+	}
+	return Brand{
+		Title:      title,
+		Color:      color,
+		HoverColor: darkenHex(color, 0.92),
+		LogoURL:    logoURL,
+	}, nil
+}
+
+// darkenHex scales each channel of a #rrggbb color by factor
+func darkenHex(color string, factor float64) string {
+	darker := "#"
+	for i := 1; i < len(color); i += 2 {
+		channel, _ := strconv.ParseUint(color[i:i+2], 16, 8)
+		darker += fmt.Sprintf("%02x", int(float64(channel)*factor))
+	}
+	return darker
+}
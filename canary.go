@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errCanaryAuth marks a canary lookup the provider refused for bad
+// credentials
+var errCanaryAuth = errors.New("provider rejected the credentials")
+
+// authFailureStatus reports whether a provider status means the token was
+// refused
+func authFailureStatus(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// runStartupCanary looks up mobile once straight through provider, so bad
+// credentials or an outage show up at boot instead of on the first user
+// request. Going around lookupService means nothing is cached or logged to
+// the database. Auth failures wrap errCanaryAuth; a missing name is fine.
+func runStartupCanary(ctx context.Context, provider NameLookupProvider, mobile string) error {
+	start := time.Now()
+	result, err := provider.Lookup(ctx, LookupRequest{
+		Mobile:    mobile,
+		ClientRef: deriveClientRef(mobile, start),
+	})
+	fields := logrus.Fields{
+		"mobile_hash": hashMobile(mobile),
+		"latency_ms":  time.Since(start).Milliseconds(),
+	}
+
+	var perr *ProviderError
+	switch {
+	case errors.As(err, &perr) && authFailureStatus(perr.StatusCode):
+		return fmt.Errorf("%w (%s returned %d)", errCanaryAuth, perr.Provider, perr.StatusCode)
+	case err != nil:
+		return fmt.Errorf("canary lookup failed: %w", err)
+	case authFailureStatus(result.StatusCode):
+		return fmt.Errorf("%w (%s returned %d)", errCanaryAuth, result.Provider, result.StatusCode)
+	}
+
+	fields["provider"], fields["name_found"] = result.Provider, result.Name != ""
+	logger.WithFields(fields).Info("Startup canary lookup succeeded")
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// parseCertPins reads a comma-separated list of SHA-256 certificate
+// fingerprints in hex, with or without colons, e.g. the output of
+// `openssl x509 -noout -fingerprint -sha256`. Listing the next certificate
+// alongside the current one allows rotating without downtime.
+func parseCertPins(value string) ([][]byte, error) {
+	var pins [][]byte
+	for _, pin := range strings.Split(value, ",") {
+		pin = strings.TrimSpace(pin)
+		if pin == "" {
+			continue
+		}
+		pin = strings.TrimPrefix(strings.ToLower(pin), "sha256 fingerprint=")
+		pin = strings.ReplaceAll(pin, ":", "")
+		b, err := hex.DecodeString(pin)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("%q is not a SHA-256 fingerprint", pin)
+		}
+		pins = append(pins, b)
+	}
+	return pins, nil
+}
+
+// pinnedCertVerifier returns a tls.Config VerifyPeerCertificate callback
+// accepting only servers whose leaf certificate matches one of pins. It
+// runs after the normal chain verification, so a pinned certificate must
+// also be valid and trusted.
+func pinnedCertVerifier(pins [][]byte) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("provider presented no certificate")
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		for _, pin := range pins {
+			if subtle.ConstantTimeCompare(sum[:], pin) == 1 {
+				return nil
+			}
+		}
+		return fmt.Errorf("provider certificate %s does not match DIGITAP_PINNED_CERT", hex.EncodeToString(sum[:]))
+	}
+}
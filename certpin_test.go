@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCertPins(t *testing.T) {
+	fingerprint := strings.Repeat("ab", 32)
+	colons := strings.TrimSuffix(strings.Repeat("AB:", 32), ":")
+
+	tests := []struct {
+		name     string
+		value    string
+		wantPins int
+		wantErr  bool
+	}{
+		{name: "unset", value: "", wantPins: 0},
+		{name: "hex", value: fingerprint, wantPins: 1},
+		{name: "openssl output", value: "SHA256 Fingerprint=" + colons, wantPins: 1},
+		{name: "two pins for rotation", value: fingerprint + ", " + colons, wantPins: 2},
+		{name: "stray commas", value: "," + fingerprint + ",", wantPins: 1},
+		{name: "not hex", value: "not-a-fingerprint", wantErr: true},
+		{name: "SHA-1 length", value: strings.Repeat("ab", 20), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins, err := parseCertPins(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCertPins(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if len(pins) != tt.wantPins {
+				t.Errorf("parseCertPins(%q) = %d pins, want %d", tt.value, len(pins), tt.wantPins)
+			}
+		})
+	}
+}
+
+func TestPinnedProviderTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+	serverPin := hex.EncodeToString(sum[:])
+	otherPin := strings.Repeat("00", 32)
+
+	tests := []struct {
+		name    string
+		pins    string
+		wantErr bool
+	}{
+		{name: "matching pin", pins: serverPin},
+		{name: "matching second pin", pins: otherPin + "," + serverPin},
+		{name: "other pin", pins: otherPin, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins, err := parseCertPins(tt.pins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := newProviderTransport(&Config{PinnedCerts: pins})
+			// Pinning runs after chain verification, so the test
+			// server's own certificate has to be trusted first
+			roots := x509.NewCertPool()
+			roots.AddCert(server.Certificate())
+			transport.TLSClientConfig.RootCAs = roots
+			defer transport.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GET error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "DIGITAP_PINNED_CERT") {
+				t.Errorf("error = %v, want the pin mismatch", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	// Each step acts on the breaker, then checks the state it is left in
+	type step struct {
+		action    string // "allow", "success", "failure", "release" or "wait"
+		wantErr   bool   // for "allow"
+		wantState string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens at the threshold",
+			steps: []step{
+				{"allow", false, circuitClosed}, {"failure", false, circuitClosed},
+				{"allow", false, circuitClosed}, {"failure", false, circuitClosed},
+				{"allow", false, circuitClosed}, {"failure", false, circuitOpen},
+				{"allow", true, circuitOpen},
+			},
+		},
+		{
+			name: "success resets the count",
+			steps: []step{
+				{"failure", false, circuitClosed}, {"failure", false, circuitClosed},
+				{"success", false, circuitClosed},
+				{"failure", false, circuitClosed}, {"failure", false, circuitClosed},
+				{"allow", false, circuitClosed},
+			},
+		},
+		{
+			name: "half-open probe succeeds",
+			steps: []step{
+				{"failure", false, circuitClosed}, {"failure", false, circuitClosed}, {"failure", false, circuitOpen},
+				{"wait", false, circuitOpen},
+				{"allow", false, circuitHalfOpen},
+				// Only one probe at a time
+				{"allow", true, circuitHalfOpen},
+				{"success", false, circuitClosed},
+				{"allow", false, circuitClosed},
+			},
+		},
+		{
+			name: "half-open probe fails",
+			steps: []step{
+				{"failure", false, circuitClosed}, {"failure", false, circuitClosed}, {"failure", false, circuitOpen},
+				{"wait", false, circuitOpen},
+				{"allow", false, circuitHalfOpen},
+				{"failure", false, circuitOpen},
+				{"allow", true, circuitOpen},
+			},
+		},
+		{
+			name: "released probe lets another through",
+			steps: []step{
+				{"failure", false, circuitClosed}, {"failure", false, circuitClosed}, {"failure", false, circuitOpen},
+				{"wait", false, circuitOpen},
+				{"allow", false, circuitHalfOpen},
+				{"release", false, circuitHalfOpen},
+				{"allow", false, circuitHalfOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			b := NewCircuitBreaker(3, time.Minute)
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				switch s.action {
+				case "allow":
+					err := b.Allow()
+					if (err != nil) != s.wantErr || err != nil && !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: Allow = %v, want error %v", i, err, s.wantErr)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "release":
+					b.Release()
+				case "wait":
+					now = now.Add(time.Minute)
+				}
+				if state := b.Snapshot()["state"]; state != s.wantState {
+					t.Fatalf("step %d (%s): state %v, want %s", i, s.action, state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestNilCircuitBreaker(t *testing.T) {
+	var b *CircuitBreaker
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("nil breaker Allow = %v, want nil", err)
+	}
+	b.Success()
+	b.Release()
+}
+
+func TestDigitapClientErrorStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCalls int
+		wantErr   bool
+		wantState string
+	}{
+		{name: "ok", status: http.StatusOK, wantCalls: 1, wantState: circuitClosed},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantCalls: 1, wantErr: true, wantState: circuitOpen},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantCalls: 2, wantErr: true, wantState: circuitOpen},
+		{name: "server error", status: http.StatusInternalServerError, wantCalls: 2, wantErr: true, wantState: circuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				// A JSON body, so only the status tells it apart
+				w.WriteHeader(tt.status)
+				io.WriteString(w, `{"result": {"mobile_linked_name": ""}}`)
+			}))
+			defer server.Close()
+
+			client := NewDigitapClient(server.URL, "token")
+			client.Breaker = NewCircuitBreaker(1, time.Minute)
+			client.MaxAttempts = 2
+
+			_, err := client.Lookup(context.Background(), LookupRequest{Mobile: "9876543210"})
+			var perr *ProviderError
+			if tt.wantErr {
+				if !errors.As(err, &perr) || perr.StatusCode != tt.status || !errors.Is(err, ErrProviderStatus) {
+					t.Errorf("Lookup error = %v, want a ProviderError with status %d", err, tt.status)
+				}
+			} else if err != nil {
+				t.Errorf("Lookup error = %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("called the provider %d times, want %d", calls, tt.wantCalls)
+			}
+			if state := client.Breaker.Snapshot()["state"]; state != tt.wantState {
+				t.Errorf("breaker state %v, want %s", state, tt.wantState)
+			}
+		})
+	}
+}
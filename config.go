@@ -0,0 +1,545 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+// ProviderConfig configures one Digitap-compatible lookup provider
+type ProviderConfig struct {
+	Name             string
+	BaseURL          string
+	AuthToken        string
+	MaxResponseBytes int64
+	// Mock serves fake names instead of calling the API; no token needed
+	Mock bool
+	// NameJSONPath is where the name is found in the response
+	NameJSONPath string
+	MaxAttempts  int
+	// ExtraFields are added to every request body
+	ExtraFields map[string]interface{}
+	// RetryMalformed retries responses that can't be parsed
+	RetryMalformed bool
+}
+
+// TransportConfig tunes the HTTP transport shared by provider calls
+type TransportConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DisableKeepAlives   bool
+	// ForceHTTP1 stops HTTP/2 from being negotiated, for providers that
+	// misbehave on it
+	ForceHTTP1 bool
+}
+
+// Config is the application configuration, read from the environment once at
+// startup by LoadConfig
+type Config struct {
+	Port             string
+	RoutePrefix      string
+	RequestTimeout   time.Duration
+	MaxBodyBytes     int64
+	TLSCertFile      string
+	TLSKeyFile       string
+	HTTPRedirectPort string
+	// TrustProxy takes the request scheme from X-Forwarded-Proto
+	TrustProxy bool
+	// RequestIDHeader, when set, names the header a gateway puts its
+	// request ID in, which is reused instead of generating one
+	RequestIDHeader string
+	Brand           Brand
+
+	LogFormat   string
+	LogLevel    string
+	LogPII      bool
+	PIIHashKey  string
+	ServiceName string
+	Environment string
+
+	DatabaseURL string
+	// DatabaseReadURL, when set, points cache reads at a read replica
+	DatabaseReadURL string
+	// DBTablePrefix is prepended to every table name
+	DBTablePrefix string
+	// StoreRawMobile keeps the number as typed alongside each cached
+	// record
+	StoreRawMobile bool
+	DBPool         db.PoolConfig
+	DBRetry        db.RetryConfig
+	MaxNameLength  int
+	// APILogSampleRate is the fraction of successful provider responses
+	// kept in api_response_logs
+	APILogSampleRate float64
+	DegradeOnDBError bool
+
+	DefaultCountryCode string
+
+	Providers []ProviderConfig
+	// ProviderProxy, when set, overrides HTTPS_PROXY/HTTP_PROXY for
+	// provider calls
+	ProviderProxy *url.URL
+	// PinnedCerts are SHA-256 fingerprints the provider's leaf
+	// certificate must match; empty means normal verification only
+	PinnedCerts [][]byte
+	// ProviderTransport tunes connection reuse for provider calls
+	ProviderTransport TransportConfig
+	// Tenants maps X-Tenant values to their own sub-account
+	Tenants          map[string]TenantConfig
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	MaxProviderCalls int
+	RejectSuspicious bool
+	DryRun           bool
+	AlwaysFresh      bool
+	NameMode         string
+	// NameBlocklist and NameBlocklistPattern pick out provider
+	// placeholder names, which are treated as not found
+	NameBlocklist        []string
+	NameBlocklistPattern *regexp.Regexp
+	MinConfidence        float64
+	StrictVerify         bool
+	MatchThreshold       float64
+	UnknownName          string
+	BatchConcurrency     int
+	// DedupeWindow is how long a provider answer is reused for repeats of
+	// the same lookup; 0 disables it
+	DedupeWindow time.Duration
+
+	// CanaryNumber is looked up once at startup when set; an auth failure
+	// stops the server if CanaryFailFast is set
+	CanaryNumber   string
+	CanaryFailFast bool
+
+	// RecordCacheSize is how many cached names are kept in memory in front
+	// of the database, each for RecordCacheTTL; 0 disables it.
+	// CacheWarmupRecords of the most recently updated are loaded into it
+	// at startup.
+	RecordCacheSize    int
+	RecordCacheTTL     time.Duration
+	CacheWarmupRecords int
+
+	BackfillConcurrency   int
+	BackfillRatePerMinute int
+
+	// MaintenanceMode answers lookups with 503 and MaintenanceMessage
+	// instead of calling the provider
+	MaintenanceMode    bool
+	MaintenanceMessage string
+
+	// ResponseLogAsync saves api_response_logs rows from a background
+	// writer, in batches of up to ResponseLogBatchSize rows written at
+	// least every ResponseLogFlushInterval. At most ResponseLogBuffer
+	// rows wait; more are dropped.
+	ResponseLogAsync         bool
+	ResponseLogBatchSize     int
+	ResponseLogFlushInterval time.Duration
+	ResponseLogBuffer        int
+
+	// ReadOnly serves cached names only: cache misses are refused instead
+	// of calling the provider, and nothing is written to the database
+	ReadOnly bool
+
+	APIKeys         []string
+	AdminKeys       []string
+	RequireKeyForUI bool
+	// CORSAllowedOrigins may call the JSON API from a browser
+	CORSAllowedOrigins []string
+
+	RateLimitPerMinute int
+	RateLimitBurst     int
+	DailyQuotaPerIP    int
+	RateLimiterBackend string
+	RedisURL           string
+
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// configLoader reads environment variables, collecting every problem so
+// they can be reported together
+type configLoader struct {
+	problems []string
+}
+
+func (l *configLoader) problem(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// string returns the variable or the default when it is unset
+func (l *configLoader) string(key, defaultValue string) string {
+	return getEnvOrDefault(key, defaultValue)
+}
+
+// secret returns a required secret from the variable itself or, when that
+// is unset, from the file named by <key>_FILE with surrounding whitespace
+// trimmed, as with secrets mounted into a Kubernetes pod
+func (l *configLoader) secret(key string) string {
+	if os.Getenv(key) == "" && os.Getenv(key+"_FILE") == "" {
+		l.problem("%s or %s_FILE is required", key, key)
+		return ""
+	}
+	return l.optionalSecret(key)
+}
+
+// optionalSecret is secret for a variable that may be left unset, in which
+// case it returns ""
+func (l *configLoader) optionalSecret(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.problem("%s_FILE: %v", key, err)
+		return ""
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		l.problem("%s_FILE: %s is empty", key, path)
+	}
+	return value
+}
+
+// bool parses a boolean variable
+func (l *configLoader) bool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.problem("%s must be true or false, got %q", key, value)
+		return defaultValue
+	}
+	return b
+}
+
+// int parses an integer variable that must be at least min and, when max
+// is non-zero, at most max
+func (l *configLoader) int(key string, defaultValue, min, max int) int {
+	n, err := getEnvInt(key, defaultValue)
+	switch {
+	case max != 0 && (err != nil || n < min || n > max):
+		l.problem("%s must be an integer between %d and %d, got %q", key, min, max, os.Getenv(key))
+	case err != nil || n < min:
+		l.problem("%s must be an integer of at least %d, got %q", key, min, os.Getenv(key))
+	default:
+		return n
+	}
+	return defaultValue
+}
+
+// float parses a number variable that must be at least min and, when max
+// is non-zero, at most max
+func (l *configLoader) float(key string, defaultValue, min, max float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	switch {
+	case max != 0 && (err != nil || f < min || f > max):
+		l.problem("%s must be a number between %g and %g, got %q", key, min, max, value)
+	case err != nil || f < min:
+		l.problem("%s must be a number of at least %g, got %q", key, min, value)
+	default:
+		return f
+	}
+	return defaultValue
+}
+
+// extraFields parses a JSON object of static provider request fields; see
+// parseExtraFields
+func (l *configLoader) extraFields(key string, defaultValue map[string]interface{}) map[string]interface{} {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	fields, err := parseExtraFields(value)
+	if err != nil {
+		l.problem("%s %v", key, err)
+		return defaultValue
+	}
+	return fields
+}
+
+// duration parses a positive duration variable
+func (l *configLoader) duration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		l.problem("%s must be a positive duration like 30s, got %q", key, value)
+		return defaultValue
+	}
+	return d
+}
+
+// durationOrZero parses a duration variable that may also be 0
+func (l *configLoader) durationOrZero(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		l.problem("%s must be a non-negative duration like 30s, got %q", key, value)
+		return defaultValue
+	}
+	return d
+}
+
+// err combines the recorded problems into one error, or nil if there were none
+func (l *configLoader) err() error {
+	if len(l.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(l.problems, "; "))
+}
+
+// LoadConfig reads and validates the configuration. Every missing or invalid
+// variable is reported in a single error rather than stopping at the first.
+func LoadConfig() (*Config, error) {
+	l := &configLoader{}
+	cfg := &Config{
+		Port:             l.string("PORT", "8080"),
+		RoutePrefix:      strings.TrimRight(os.Getenv("ROUTE_PREFIX"), "/"),
+		RequestTimeout:   l.duration("REQUEST_TIMEOUT", 30*time.Second),
+		MaxBodyBytes:     int64(l.int("MAX_BODY_BYTES", 64<<10, 1, 0)),
+		TLSCertFile:      os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:       os.Getenv("TLS_KEY_FILE"),
+		HTTPRedirectPort: os.Getenv("HTTP_REDIRECT_PORT"),
+		TrustProxy:       l.bool("TRUST_PROXY", false),
+		RequestIDHeader:  strings.TrimSpace(os.Getenv("REQUEST_ID_HEADER")),
+
+		LogFormat:   l.string("LOG_FORMAT", "json"),
+		LogLevel:    l.string("LOG_LEVEL", "info"),
+		LogPII:      l.bool("LOG_PII", false),
+		PIIHashKey:  os.Getenv("PII_HASH_KEY"),
+		ServiceName: l.string("SERVICE_NAME", "mobile-name-lookup"),
+		Environment: l.string("ENVIRONMENT", os.Getenv("RAILWAY_ENVIRONMENT")),
+
+		DatabaseURL:      l.secret("DATABASE_URL"),
+		DatabaseReadURL:  l.optionalSecret("DATABASE_READ_URL"),
+		DBTablePrefix:    os.Getenv("DB_TABLE_PREFIX"),
+		StoreRawMobile:   l.bool("STORE_RAW_MOBILE", false),
+		MaxNameLength:    l.int("MAX_NAME_LENGTH", 255, 1, 255),
+		APILogSampleRate: l.float("API_LOG_SAMPLE_RATE", 1, 0, 1),
+		DBPool: db.PoolConfig{
+			MaxOpenConns:    l.int("DB_MAX_OPEN_CONNS", 25, 1, 0),
+			MaxIdleConns:    l.int("DB_MAX_IDLE_CONNS", 25, 0, 0),
+			ConnMaxLifetime: l.durationOrZero("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			MaxLockConns:    l.int("DB_MAX_LOCK_CONNS", 10, 1, 0),
+		},
+		DBRetry: db.RetryConfig{
+			Attempts: l.int("DB_CONNECT_ATTEMPTS", 5, 1, 0),
+			Delay:    l.durationOrZero("DB_CONNECT_DELAY", 2*time.Second),
+			MaxDelay: 30 * time.Second,
+		},
+		DegradeOnDBError: l.bool("DEGRADE_ON_DB_ERROR", false),
+
+		DefaultCountryCode: strings.TrimPrefix(l.string("DEFAULT_COUNTRY_CODE", "91"), "+"),
+
+		BreakerThreshold: l.int("CIRCUIT_BREAKER_THRESHOLD", 5, 0, 0),
+		BreakerCooldown:  l.duration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second),
+		MaxProviderCalls: l.int("MAX_CONCURRENT_PROVIDER_CALLS", 0, 0, 0),
+		ProviderTransport: TransportConfig{
+			MaxIdleConns:        l.int("PROVIDER_MAX_IDLE_CONNS", 100, 1, 0),
+			MaxIdleConnsPerHost: l.int("PROVIDER_MAX_IDLE_CONNS_PER_HOST", 100, 1, 0),
+			IdleConnTimeout:     l.duration("PROVIDER_IDLE_CONN_TIMEOUT", 90*time.Second),
+			DisableKeepAlives:   l.bool("PROVIDER_DISABLE_KEEPALIVES", false),
+			ForceHTTP1:          l.bool("PROVIDER_FORCE_HTTP1", false),
+		},
+
+		RejectSuspicious: l.bool("REJECT_SUSPICIOUS_NUMBERS", true),
+		DryRun:           l.bool("DRY_RUN", false),
+		AlwaysFresh:      l.bool("ALWAYS_FRESH", false),
+		NameMode:         l.string("NORMALIZE_NAMES", nameNormalizeOff),
+		MinConfidence:    l.float("MIN_CONFIDENCE", 0, 0, 0),
+		StrictVerify:     l.bool("VERIFY_NAME_STRICT", false),
+		MatchThreshold:   l.float("VERIFY_MATCH_THRESHOLD", 85, 0, 100),
+		UnknownName:      strings.TrimSpace(os.Getenv("DEFAULT_UNKNOWN_NAME")),
+		BatchConcurrency: l.int("BATCH_CONCURRENCY", 5, 1, 0),
+		DedupeWindow:     time.Duration(l.int("DEDUPE_WINDOW_SECONDS", 5, 0, 300)) * time.Second,
+
+		CanaryNumber:   os.Getenv("STARTUP_CANARY_NUMBER"),
+		CanaryFailFast: l.bool("STARTUP_CANARY_FAIL_FAST", true),
+
+		RecordCacheSize:    l.int("RECORD_CACHE_SIZE", 0, 0, 1000000),
+		RecordCacheTTL:     l.duration("RECORD_CACHE_TTL", time.Minute),
+		CacheWarmupRecords: l.int("CACHE_WARMUP_RECORDS", 0, 0, 1000000),
+
+		BackfillConcurrency:   l.int("BACKFILL_CONCURRENCY", 2, 1, 0),
+		BackfillRatePerMinute: l.int("BACKFILL_RATE_PER_MINUTE", 60, 1, 0),
+
+		MaintenanceMode:    l.bool("MAINTENANCE_MODE", false),
+		MaintenanceMessage: l.string("MAINTENANCE_MESSAGE", defaultMaintenanceMessage),
+		ReadOnly:           l.bool("READ_ONLY", false),
+
+		ResponseLogAsync:         l.bool("RESPONSE_LOG_ASYNC", false),
+		ResponseLogBatchSize:     l.int("RESPONSE_LOG_BATCH_SIZE", 100, 1, 1000),
+		ResponseLogFlushInterval: l.duration("RESPONSE_LOG_FLUSH_INTERVAL", 500*time.Millisecond),
+		ResponseLogBuffer:        l.int("RESPONSE_LOG_BUFFER", 10000, 1, 0),
+
+		APIKeys:         parseAPIKeys(os.Getenv("API_KEYS")),
+		AdminKeys:       parseAPIKeys(os.Getenv("ADMIN_API_KEYS")),
+		RequireKeyForUI: l.bool("REQUIRE_API_KEY_FOR_UI", false),
+
+		RateLimitPerMinute: l.int("RATE_LIMIT_PER_MINUTE", 5, 1, 0),
+		RateLimitBurst:     l.int("RATE_LIMIT_BURST", 5, 1, 0),
+		DailyQuotaPerIP:    l.int("DAILY_QUOTA_PER_IP", 0, 0, 0),
+		RateLimiterBackend: os.Getenv("RATE_LIMITER_BACKEND"),
+		RedisURL:           l.string("REDIS_URL", "redis://localhost:6379/0"),
+
+		WebhookURL:    os.Getenv("WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+	}
+
+	if cfg.RoutePrefix != "" && !strings.HasPrefix(cfg.RoutePrefix, "/") {
+		l.problem("ROUTE_PREFIX must start with /, got %q", cfg.RoutePrefix)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		l.problem("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if _, ok := countryRules[cfg.DefaultCountryCode]; !ok {
+		l.problem("DEFAULT_COUNTRY_CODE must be 91, 1 or 44, got %q", cfg.DefaultCountryCode)
+	}
+	var err error
+	if cfg.Brand, err = newBrand(l.string("BRAND_TITLE", defaultBrandTitle), l.string("BRAND_COLOR", defaultBrandColor), os.Getenv("LOGO_URL")); err != nil {
+		l.problem("%v", err)
+	}
+	cfg.NameBlocklist = strings.Split(l.string("NAME_BLOCKLIST", defaultNameBlocklist), ",")
+	if pattern := os.Getenv("NAME_BLOCKLIST_REGEX"); pattern != "" {
+		if cfg.NameBlocklistPattern, err = regexp.Compile("(?i)" + pattern); err != nil {
+			l.problem("NAME_BLOCKLIST_REGEX: %v", err)
+		}
+	}
+	if cfg.RequestIDHeader != "" && !validRequestIDHeader(cfg.RequestIDHeader) {
+		l.problem("REQUEST_ID_HEADER must be a header name like X-Request-ID, got %q", cfg.RequestIDHeader)
+	}
+	if !validNameMode(cfg.NameMode) {
+		l.problem("NORMALIZE_NAMES must be off, on or title, got %q", cfg.NameMode)
+	}
+	if !db.ValidTablePrefix(cfg.DBTablePrefix) {
+		l.problem("DB_TABLE_PREFIX must be at most 32 letters, digits or underscores, got %q", cfg.DBTablePrefix)
+	}
+	if cfg.RateLimiterBackend != "" && cfg.RateLimiterBackend != "redis" {
+		l.problem("RATE_LIMITER_BACKEND must be empty or redis, got %q", cfg.RateLimiterBackend)
+	}
+
+	if cfg.CacheWarmupRecords > 0 && cfg.RecordCacheSize == 0 {
+		l.problem("CACHE_WARMUP_RECORDS needs RECORD_CACHE_SIZE, the in-memory cache it warms")
+	}
+	if cfg.DBPool.MaxIdleConns > cfg.DBPool.MaxOpenConns {
+		l.problem("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", cfg.DBPool.MaxIdleConns, cfg.DBPool.MaxOpenConns)
+	}
+
+	cfg.Providers = loadProviderConfigs(l)
+	if value := os.Getenv("DIGITAP_PROXY_URL"); value != "" {
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			l.problem("DIGITAP_PROXY_URL must be an http, https or socks5 URL, got %q", value)
+		} else {
+			cfg.ProviderProxy = u
+		}
+	}
+	if cfg.PinnedCerts, err = parseCertPins(os.Getenv("DIGITAP_PINNED_CERT")); err != nil {
+		l.problem("DIGITAP_PINNED_CERT: %v", err)
+	}
+	if cfg.CORSAllowedOrigins, err = parseCORSOrigins(os.Getenv("CORS_ALLOWED_ORIGINS")); err != nil {
+		l.problem("CORS_ALLOWED_ORIGINS: %v", err)
+	}
+	cfg.Tenants = loadTenants(l)
+
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadProviderConfigs reads LOOKUP_PROVIDERS, an ordered, comma-separated
+// list of env prefixes (default "DIGITAP"). Each prefix configures a
+// Digitap-compatible endpoint through <PREFIX>_BASE_URL and
+// <PREFIX>_AUTH_TOKEN (or <PREFIX>_AUTH_TOKEN_FILE), so a backup account or
+// reseller can be listed after the primary, e.g. "DIGITAP,DIGITAP_BACKUP".
+// <PREFIX>_MOCK replaces the endpoint with fake names for local development.
+// <PREFIX>_NAME_JSON_PATH, defaulting to PROVIDER_NAME_JSON_PATH, locates the
+// name in a reseller's response. <PREFIX>_EXTRA_FIELDS, defaulting to
+// PROVIDER_EXTRA_FIELDS, adds static fields to the request body.
+func loadProviderConfigs(l *configLoader) []ProviderConfig {
+	maxResponseBytes := int64(l.int("MAX_PROVIDER_RESPONSE_BYTES", defaultMaxResponseBytes, 1, 0))
+	nameJSONPath := l.string("PROVIDER_NAME_JSON_PATH", defaultNameJSONPath)
+	extraFields := l.extraFields("PROVIDER_EXTRA_FIELDS", nil)
+	retryMalformed := l.bool("PROVIDER_RETRY_MALFORMED", false)
+
+	var providers []ProviderConfig
+	for _, prefix := range strings.Split(l.string("LOOKUP_PROVIDERS", "DIGITAP"), ",") {
+		prefix = strings.ToUpper(strings.TrimSpace(prefix))
+		if prefix == "" {
+			continue
+		}
+
+		provider := ProviderConfig{
+			Name:    strings.ToLower(prefix),
+			BaseURL: l.string(prefix+"_BASE_URL", "https://svc.digitap.ai"),
+			Mock:    l.bool(prefix+"_MOCK", false),
+
+			MaxResponseBytes: maxResponseBytes,
+			NameJSONPath:     l.string(prefix+"_NAME_JSON_PATH", nameJSONPath),
+			MaxAttempts:      l.int(prefix+"_MAX_RETRIES", defaultMaxAttempts-1, 0, 9) + 1, // retries after the first attempt
+			ExtraFields:      l.extraFields(prefix+"_EXTRA_FIELDS", extraFields),
+			RetryMalformed:   retryMalformed,
+		}
+		if err := validJSONPath(provider.NameJSONPath); err != nil {
+			l.problem("%s_NAME_JSON_PATH: %v", prefix, err)
+		}
+		if !provider.Mock {
+			provider.AuthToken = l.secret(prefix + "_AUTH_TOKEN")
+		}
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 0 {
+		l.problem("LOOKUP_PROVIDERS must list at least one provider")
+	}
+	return providers
+}
+
+// loadTenants reads the per-tenant sub-accounts from the JSON in
+// TENANTS_JSON or, when that is unset, the file named by TENANTS_FILE.
+// Neither being set leaves every request on the global providers.
+func loadTenants(l *configLoader) map[string]TenantConfig {
+	data := []byte(os.Getenv("TENANTS_JSON"))
+	if len(data) == 0 {
+		path := os.Getenv("TENANTS_FILE")
+		if path == "" {
+			return nil
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			l.problem("TENANTS_FILE: %v", err)
+			return nil
+		}
+	}
+
+	tenants, err := parseTenants(data)
+	if err != nil {
+		l.problem("tenants: %v", err)
+		return nil
+	}
+	return tenants
+}
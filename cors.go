@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
+)
+
+// parseCORSOrigins reads a comma-separated list of origins such as
+// https://app.example.com. Every origin must be listed explicitly; "*" is
+// refused so a typo can't open the API to every site.
+func parseCORSOrigins(value string) ([]string, error) {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if strings.Contains(origin, "*") {
+			return nil, fmt.Errorf("wildcards are not allowed, list each origin instead")
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+			return nil, fmt.Errorf("%q is not an origin like https://app.example.com", origin)
+		}
+		origins = append(origins, strings.ToLower(origin))
+	}
+	return origins, nil
+}
+
+// Middleware adding CORS headers to /api/ responses for the allowed
+// origins and answering their preflight requests. An API request from any
+// other origin gets 403 rather than a response the browser would only
+// discard; requests without an Origin, like those from curl or the mobile
+// app, and same-origin requests are unaffected. HTML routes never get
+// CORS headers.
+func corsMiddleware(next http.Handler, allowed []string) http.Handler {
+	c := cors.New(cors.Options{
+		AllowedOrigins: allowed,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedHeaders: []string{"Content-Type", "Accept", "X-API-Key", "X-Tenant", "X-Request-ID"},
+		ExposedHeaders: []string{
+			"X-Request-ID", "X-Cache-Bypassed", "Retry-After",
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-Quota-Limit", "X-Quota-Remaining",
+		},
+		MaxAge: 600,
+	})
+	api := c.Handler(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAPIPath(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		origin := r.Header.Get("Origin")
+		if origin != "" && !strings.EqualFold(origin, absoluteURL(r, "")) && !c.OriginAllowed(r) {
+			logger.WithFields(logrus.Fields{
+				"origin": origin,
+				"path":   r.URL.Path,
+				"ip":     r.RemoteAddr,
+			}).Warn("Rejected API request from an origin not in CORS_ALLOWED_ORIGINS")
+			writeJSONError(w, http.StatusForbidden, errCodeForbidden, "Origin not allowed")
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+}
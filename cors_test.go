@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCORSOrigins(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "https://app.example.com", want: []string{"https://app.example.com"}},
+		{value: " https://App.Example.com/ , http://localhost:3000", want: []string{"https://app.example.com", "http://localhost:3000"}},
+		{value: "*", wantErr: true},
+		{value: "https://*.example.com", wantErr: true},
+		{value: "app.example.com", wantErr: true},
+		{value: "ftp://app.example.com", wantErr: true},
+		{value: "https://app.example.com/path", wantErr: true},
+		{value: "https://user@app.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCORSOrigins(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCORSOrigins(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseCORSOrigins(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseCORSOrigins(%q) = %v, want %v", tt.value, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), []string{"https://app.example.com"})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantAllowed string // Access-Control-Allow-Origin
+	}{
+		{name: "allowed origin", method: http.MethodGet, path: "/api/v1/lookup", origin: "https://app.example.com", wantStatus: http.StatusOK, wantAllowed: "https://app.example.com"},
+		{name: "other origin", method: http.MethodGet, path: "/api/v1/lookup", origin: "https://evil.example.com", wantStatus: http.StatusForbidden},
+		{name: "no origin", method: http.MethodGet, path: "/api/v1/lookup", wantStatus: http.StatusOK},
+		{name: "same origin", method: http.MethodPost, path: "/api/v1/lookup", origin: "http://lookup.example.com", wantStatus: http.StatusOK},
+		{name: "HTML route", method: http.MethodPost, path: "/lookup_post", origin: "https://evil.example.com", wantStatus: http.StatusOK},
+		{name: "preflight", method: http.MethodOptions, path: "/api/v1/batch", origin: "https://app.example.com", preflight: true, wantStatus: http.StatusNoContent, wantAllowed: "https://app.example.com"},
+		{name: "preflight from other origin", method: http.MethodOptions, path: "/api/v1/batch", origin: "https://evil.example.com", preflight: true, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://lookup.example.com"+tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				// As browsers send it: lower-case and sorted
+				req.Header.Set("Access-Control-Request-Headers", "content-type,x-api-key")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidCSRFToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string // "" for no cookie
+		field  string
+		want   bool
+	}{
+		{"matching", "token-a", "token-a", true},
+		{"different", "token-a", "token-b", false},
+		{"missing field", "token-a", "", false},
+		{"missing cookie", "", "token-a", false},
+		{"both empty", "", "", false},
+		{"prefix only", "token-a", "token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.field != "" {
+				form.Set(csrfFieldName, tt.field)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/lookup_post", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+			}
+			req.ParseForm()
+
+			if got := validCSRFToken(req); got != tt.want {
+				t.Errorf("validCSRFToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidCSRFTokenIgnoresQuery(t *testing.T) {
+	// Only the posted body counts, so a token can't be smuggled in a link
+	req := httptest.NewRequest(http.MethodPost, "/lookup_post?"+csrfFieldName+"=token-a", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token-a"})
+	req.ParseForm()
+
+	if validCSRFToken(req) {
+		t.Error("accepted a token from the query string")
+	}
+}
+
+func TestEnsureCSRFToken(t *testing.T) {
+	// A new visitor gets a fresh token in a cookie
+	rec := httptest.NewRecorder()
+	token, err := ensureCSRFToken(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookieName || cookies[0].Value != token {
+		t.Fatalf("cookies = %v, want %s=%s", cookies, csrfCookieName, token)
+	}
+	if !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie %v is not HttpOnly and SameSite=Strict", cookies[0])
+	}
+	if len(token) < 40 {
+		t.Errorf("token %q is shorter than %d random bytes allow", token, csrfTokenBytes)
+	}
+
+	// A returning visitor keeps theirs
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+	again, err := ensureCSRFToken(rec, req)
+	if err != nil || again != token {
+		t.Errorf("ensureCSRFToken = %q, %v; want the existing %q", again, err, token)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("reissued the cookie to a visitor who has one")
+	}
+
+	// Tokens are not reused between visitors
+	other, _ := ensureCSRFToken(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if other == token {
+		t.Error("two visitors got the same token")
+	}
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records, overrides and response logs in memory. It
+// mirrors DB closely enough to stand in for MySQL in tests and local runs;
+// nothing survives a restart.
+type MemoryStore struct {
+	// MaxNameLength caps stored names as in DB
+	MaxNameLength int
+
+	mu        sync.Mutex
+	nextID    int64
+	records   map[string]*MobileRecord
+	overrides map[string]*Override
+	logs      []*APIResponseLog
+	audit     []*AdminAuditEntry
+	locks     map[string]chan struct{}
+	usage     map[string]int
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:   make(map[string]*MobileRecord),
+		overrides: make(map[string]*Override),
+		locks:     make(map[string]chan struct{}),
+		usage:     make(map[string]int),
+	}
+}
+
+// GetMobileRecord returns a copy of the record for mobile, or nil if none
+func (m *MemoryStore) GetMobileRecord(ctx context.Context, mobile string) (*MobileRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[mobile]
+	if !ok {
+		return nil, nil
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// GetMobileRecordFromPrimary is GetMobileRecord; there is no replica
+func (m *MemoryStore) GetMobileRecordFromPrimary(ctx context.Context, mobile string) (*MobileRecord, error) {
+	return m.GetMobileRecord(ctx, mobile)
+}
+
+// SaveMobileRecord creates or updates the record for mobile, sanitizing the
+// name as DB does
+func (m *MemoryStore) SaveMobileRecord(ctx context.Context, mobile, name, rawMobile string) error {
+	maxLength := m.MaxNameLength
+	if maxLength <= 0 || maxLength > nameColumnSize {
+		maxLength = nameColumnSize
+	}
+	if name = sanitizeName(name, maxLength); name == "" {
+		return fmt.Errorf("error saving mobile record: name is empty after sanitizing")
+	}
+	rawMobile = sanitizeName(rawMobile, rawMobileColumnSize)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if record, ok := m.records[mobile]; ok {
+		record.Name, record.RawMobile, record.UpdatedAt = name, rawMobile, now
+		return nil
+	}
+	m.nextID++
+	m.records[mobile] = &MobileRecord{ID: m.nextID, Mobile: mobile, Name: name, RawMobile: rawMobile, CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+// FindByName returns records whose name equals name, or starts with it when
+// prefix is set, case-insensitively and most recently updated first
+func (m *MemoryStore) FindByName(ctx context.Context, name string, prefix bool, limit, offset int) ([]*MobileRecord, error) {
+	return m.find(func(record *MobileRecord) bool {
+		if prefix {
+			return strings.HasPrefix(strings.ToLower(record.Name), strings.ToLower(name))
+		}
+		return strings.EqualFold(record.Name, name)
+	}, limit, offset), nil
+}
+
+// ListMobileRecords returns records whose mobile or name contains search,
+// case-insensitively, or all records when search is empty, most recently
+// updated first
+func (m *MemoryStore) ListMobileRecords(ctx context.Context, search string, limit, offset int) ([]*MobileRecord, error) {
+	search = strings.ToLower(search)
+	return m.find(func(record *MobileRecord) bool {
+		return strings.Contains(record.Mobile, search) || strings.Contains(strings.ToLower(record.Name), search)
+	}, limit, offset), nil
+}
+
+// SearchBySuffix returns up to limit records whose mobile ends with suffix
+func (m *MemoryStore) SearchBySuffix(ctx context.Context, suffix string, limit int) ([]*MobileRecord, error) {
+	return m.find(func(record *MobileRecord) bool {
+		return strings.HasSuffix(record.Mobile, suffix)
+	}, limit, 0), nil
+}
+
+// find returns copies of the matching records in DB's result order
+func (m *MemoryStore) find(match func(*MobileRecord) bool, limit, offset int) []*MobileRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := []*MobileRecord{}
+	for _, record := range m.records {
+		if match(record) {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].UpdatedAt.Equal(records[j].UpdatedAt) {
+			return records[i].UpdatedAt.After(records[j].UpdatedAt)
+		}
+		return records[i].ID > records[j].ID
+	})
+
+	if offset >= len(records) {
+		return []*MobileRecord{}
+	}
+	records = records[offset:]
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
+
+// DeleteStaleRecords deletes records last updated before olderThan
+func (m *MemoryStore) DeleteStaleRecords(ctx context.Context, olderThan time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for mobile, record := range m.records {
+		if record.UpdatedAt.Before(olderThan) {
+			delete(m.records, mobile)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// UnresolvedMobiles returns up to limit logged numbers greater than after
+// that have no cached name, in ascending order
+func (m *MemoryStore) UnresolvedMobiles(ctx context.Context, after string, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var mobiles []string
+	for _, entry := range m.logs {
+		if _, cached := m.records[entry.Mobile]; cached || seen[entry.Mobile] || entry.Mobile <= after {
+			continue
+		}
+		seen[entry.Mobile] = true
+		mobiles = append(mobiles, entry.Mobile)
+	}
+
+	sort.Strings(mobiles)
+	if len(mobiles) > limit {
+		mobiles = mobiles[:limit]
+	}
+	return mobiles, nil
+}
+
+// IncrementDailyUsage adds one to client's count for the UTC day containing
+// day and returns the new count
+func (m *MemoryStore) IncrementDailyUsage(ctx context.Context, client string, day time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := client + "|" + day.UTC().Format("2006-01-02")
+	m.usage[key]++
+	return m.usage[key], nil
+}
+
+// GetOverride returns a copy of the override for mobile, or nil if none
+func (m *MemoryStore) GetOverride(ctx context.Context, mobile string) (*Override, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	override, ok := m.overrides[mobile]
+	if !ok {
+		return nil, nil
+	}
+	copied := *override
+	return &copied, nil
+}
+
+// SetOverride creates or replaces the override for mobile
+func (m *MemoryStore) SetOverride(ctx context.Context, mobile, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if override, ok := m.overrides[mobile]; ok {
+		override.Name, override.UpdatedAt = name, now
+		return nil
+	}
+	m.overrides[mobile] = &Override{Mobile: mobile, Name: name, CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+// DeleteOverride removes the override for mobile, reporting whether one existed
+func (m *MemoryStore) DeleteOverride(ctx context.Context, mobile string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.overrides[mobile]
+	delete(m.overrides, mobile)
+	return ok, nil
+}
+
+// SaveAPIResponseLog appends a copy of entry to the response log
+func (m *MemoryStore) SaveAPIResponseLog(ctx context.Context, entry *APIResponseLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	copied := *entry
+	copied.ID, copied.CreatedAt = m.nextID, time.Now()
+	m.logs = append(m.logs, &copied)
+	return nil
+}
+
+// SaveAPIResponseLogs appends copies of entries to the response log
+func (m *MemoryStore) SaveAPIResponseLogs(ctx context.Context, entries []*APIResponseLog) error {
+	for _, entry := range entries {
+		m.SaveAPIResponseLog(ctx, entry)
+	}
+	return nil
+}
+
+// APIResponseLogs returns copies of the saved response logs, oldest first
+func (m *MemoryStore) APIResponseLogs() []*APIResponseLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	logs := make([]*APIResponseLog, len(m.logs))
+	for i, entry := range m.logs {
+		copied := *entry
+		logs[i] = &copied
+	}
+	return logs
+}
+
+// SaveAdminAudit appends a copy of entry to the audit trail
+func (m *MemoryStore) SaveAdminAudit(ctx context.Context, entry *AdminAuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	copied := *entry
+	copied.ID, copied.CreatedAt = m.nextID, time.Now()
+	m.audit = append(m.audit, &copied)
+	return nil
+}
+
+// RecentLookups returns copies of the latest response logs, newest first,
+// without their results as DB does
+func (m *MemoryStore) RecentLookups(ctx context.Context, limit int) ([]*APIResponseLog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []*APIResponseLog
+	for i := len(m.logs) - 1; i >= 0 && len(entries) < limit; i-- {
+		copied := *m.logs[i]
+		copied.Result = ""
+		entries = append(entries, &copied)
+	}
+	return entries, nil
+}
+
+// ListAdminAudit returns copies of audit entries, newest first
+func (m *MemoryStore) ListAdminAudit(ctx context.Context, limit, offset int) ([]*AdminAuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []*AdminAuditEntry
+	for i := len(m.audit) - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		copied := *m.audit[i]
+		entries = append(entries, &copied)
+	}
+	return entries, nil
+}
+
+// LockMobile takes an in-process lock for mobile, waiting up to timeout
+func (m *MemoryStore) LockMobile(ctx context.Context, mobile string, timeout time.Duration) (func(), error) {
+	m.mu.Lock()
+	lock, ok := m.locks[mobile]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		m.locks[mobile] = lock
+	}
+	m.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out waiting for lookup lock")
+	case <-ctx.Done():
+		return nil, fmt.Errorf("error acquiring lookup lock: %v", ctx.Err())
+	}
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seededStore saves records in order, so later ones are the most recently
+// updated
+func seededStore(t *testing.T, records ...[2]string) *MemoryStore {
+	t.Helper()
+	m := NewMemoryStore()
+	for _, r := range records {
+		if err := m.SaveMobileRecord(context.Background(), r[0], r[1], ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func mobilesOf(records []*MobileRecord) string {
+	mobiles := make([]string, len(records))
+	for i, r := range records {
+		mobiles[i] = r.Mobile
+	}
+	return strings.Join(mobiles, ",")
+}
+
+func TestMemoryStoreSaveMobileRecord(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	m.MaxNameLength = 10
+
+	if err := m.SaveMobileRecord(ctx, "9876543210", "  Ravi Kumar Sharma", "+91 98765 43210"); err != nil {
+		t.Fatal(err)
+	}
+	record, _ := m.GetMobileRecord(ctx, "9876543210")
+	if record == nil || record.Name != "Ravi Kumar" || record.RawMobile != "+91 98765 43210" {
+		t.Fatalf("saved record = %+v, want name Ravi Kumar and the raw number", record)
+	}
+
+	// Updating keeps the ID
+	if err := m.SaveMobileRecord(ctx, "9876543210", "Asha", ""); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := m.GetMobileRecord(ctx, "9876543210")
+	if updated.ID != record.ID || updated.Name != "Asha" {
+		t.Errorf("updated record = %+v, want ID %d and name Asha", updated, record.ID)
+	}
+
+	// Returned records are copies
+	updated.Name = "Changed"
+	if again, _ := m.GetMobileRecord(ctx, "9876543210"); again.Name != "Asha" {
+		t.Error("changing a returned record changed the store")
+	}
+
+	if err := m.SaveMobileRecord(ctx, "9876543211", "\x00 ", ""); err == nil {
+		t.Error("saved a name that is empty after sanitizing")
+	}
+	if missing, err := m.GetMobileRecord(ctx, "9123456780"); missing != nil || err != nil {
+		t.Errorf("GetMobileRecord for an unknown number = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+func TestMemoryStoreQueries(t *testing.T) {
+	m := seededStore(t,
+		[2]string{"9000000001", "Ravi Kumar"},
+		[2]string{"9000000002", "ravi kumar"},
+		[2]string{"9000000013", "Ravindra Rao"},
+		[2]string{"9000000004", "Asha Rao"},
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		query func() ([]*MobileRecord, error)
+		want  string
+	}{
+		{"list newest first", func() ([]*MobileRecord, error) { return m.ListMobileRecords(ctx, "", 10, 0) }, "9000000004,9000000013,9000000002,9000000001"},
+		{"list paged", func() ([]*MobileRecord, error) { return m.ListMobileRecords(ctx, "", 2, 1) }, "9000000013,9000000002"},
+		{"list past the end", func() ([]*MobileRecord, error) { return m.ListMobileRecords(ctx, "", 2, 10) }, ""},
+		{"list by name", func() ([]*MobileRecord, error) { return m.ListMobileRecords(ctx, "RAO", 10, 0) }, "9000000004,9000000013"},
+		{"list by number", func() ([]*MobileRecord, error) { return m.ListMobileRecords(ctx, "0013", 10, 0) }, "9000000013"},
+		{"exact name ignores case", func() ([]*MobileRecord, error) { return m.FindByName(ctx, "RAVI KUMAR", false, 10, 0) }, "9000000002,9000000001"},
+		{"name prefix", func() ([]*MobileRecord, error) { return m.FindByName(ctx, "ravi", true, 10, 0) }, "9000000013,9000000002,9000000001"},
+		{"suffix", func() ([]*MobileRecord, error) { return m.SearchBySuffix(ctx, "3", 10) }, "9000000013"},
+		{"suffix limited", func() ([]*MobileRecord, error) { return m.SearchBySuffix(ctx, "", 2) }, "9000000004,9000000013"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := tt.query()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mobilesOf(records); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreOverrides(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+
+	if err := m.SetOverride(ctx, "9876543210", "Asha"); err != nil {
+		t.Fatal(err)
+	}
+	if override, _ := m.GetOverride(ctx, "9876543210"); override == nil || override.Name != "Asha" {
+		t.Fatalf("override = %+v, want Asha", override)
+	}
+	if deleted, _ := m.DeleteOverride(ctx, "9876543210"); !deleted {
+		t.Error("DeleteOverride reported nothing deleted")
+	}
+	if deleted, _ := m.DeleteOverride(ctx, "9876543210"); deleted {
+		t.Error("DeleteOverride deleted the same override twice")
+	}
+	if override, _ := m.GetOverride(ctx, "9876543210"); override != nil {
+		t.Errorf("override after delete = %+v, want nil", override)
+	}
+}
+
+func TestMemoryStoreUnresolvedMobiles(t *testing.T) {
+	ctx := context.Background()
+	m := seededStore(t, [2]string{"9000000002", "Ravi"})
+	for _, mobile := range []string{"9000000003", "9000000001", "9000000002", "9000000003", "9000000004"} {
+		m.SaveAPIResponseLog(ctx, &APIResponseLog{Mobile: mobile, Status: "success"})
+	}
+
+	got, _ := m.UnresolvedMobiles(ctx, "", 10)
+	if strings.Join(got, ",") != "9000000001,9000000003,9000000004" {
+		t.Errorf("UnresolvedMobiles = %v, want the uncached numbers once each, in order", got)
+	}
+	got, _ = m.UnresolvedMobiles(ctx, "9000000001", 1)
+	if strings.Join(got, ",") != "9000000003" {
+		t.Errorf("UnresolvedMobiles after 9000000001, limit 1 = %v, want [9000000003]", got)
+	}
+}
+
+func TestMemoryStoreDailyUsage(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	day := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+
+	for want := 1; want <= 3; want++ {
+		if got, _ := m.IncrementDailyUsage(ctx, "client-a", day); got != want {
+			t.Errorf("usage = %d, want %d", got, want)
+		}
+	}
+	if got, _ := m.IncrementDailyUsage(ctx, "client-b", day); got != 1 {
+		t.Errorf("another client's usage = %d, want 1", got)
+	}
+	if got, _ := m.IncrementDailyUsage(ctx, "client-a", day.Add(2*time.Hour)); got != 1 {
+		t.Errorf("next day's usage = %d, want 1", got)
+	}
+}
+
+func TestMemoryStoreLockMobile(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+
+	unlock, err := m.LockMobile(ctx, "9876543210", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.LockMobile(ctx, "9876543210", 10*time.Millisecond); err == nil {
+		t.Error("took a held lock")
+	}
+	other, err := m.LockMobile(ctx, "9123456780", 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("locking another number: %v", err)
+	} else {
+		other()
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := m.LockMobile(cancelled, "9876543210", time.Second); err == nil {
+		t.Error("took a held lock with a cancelled context")
+	}
+
+	unlock()
+	again, err := m.LockMobile(ctx, "9876543210", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("lock not released: %v", err)
+	}
+	again()
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migration is a single versioned schema change. Migrations are applied in
+// order and recorded in schema_migrations so each runs exactly once per
+// database.
+type migration struct {
+	version int
+	name    string
+	// up applies the change; table names are prefixed with prefix
+	up func(tx *sql.Tx, prefix string) error
+}
+
+// migrations lists every schema change in order. Never edit or reorder an
+// applied migration; append a new one instead. The migrations for tables
+// and indexes that predate the runner use IF NOT EXISTS, or add the index
+// only when missing, so databases created before it adopt them cleanly.
+var migrations = []migration{
+	{1, "initial_schema", execStatements(`
+	CREATE TABLE IF NOT EXISTS {prefix}mobile_records (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		mobile VARCHAR(10) UNIQUE NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);`)},
+	{2, "create_api_response_logs", execStatements(`
+	CREATE TABLE IF NOT EXISTS {prefix}api_response_logs (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		mobile VARCHAR(10) NOT NULL,
+		status VARCHAR(50) NOT NULL,
+		result TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_api_response_logs_mobile (mobile)
+	);`)},
+	{3, "add_mobile_records_name_index", addIndex("mobile_records", "idx_mobile_records_name", "name")},
+	{4, "create_overrides", execStatements(`
+	CREATE TABLE {prefix}overrides (
+		mobile VARCHAR(10) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);`)},
+	{5, "add_api_response_logs_provider_columns", execStatements(`
+	ALTER TABLE {prefix}api_response_logs
+		ADD COLUMN provider VARCHAR(64) NOT NULL DEFAULT '',
+		ADD COLUMN status_code INT NOT NULL DEFAULT 0,
+		ADD COLUMN latency_ms INT NOT NULL DEFAULT 0,
+		ADD INDEX idx_api_response_logs_provider (provider, created_at);`)},
+	{6, "create_daily_usage", execStatements(`
+	CREATE TABLE {prefix}daily_usage (
+		client VARCHAR(64) NOT NULL,
+		day DATE NOT NULL,
+		count INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (client, day)
+	);`)},
+	{7, "create_admin_audit", execStatements(`
+	CREATE TABLE {prefix}admin_audit (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		key_id VARCHAR(16) NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		mobile VARCHAR(10) NOT NULL DEFAULT '',
+		detail VARCHAR(255) NOT NULL DEFAULT '',
+		client_ip VARCHAR(64) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_admin_audit_mobile (mobile)
+	);`)},
+	{8, "add_mobile_records_raw_mobile", execStatements(`
+	ALTER TABLE {prefix}mobile_records
+		ADD COLUMN raw_mobile VARCHAR(64) NULL;`)},
+	{9, "add_api_response_logs_created_at_index", addIndex("api_response_logs", "idx_api_response_logs_created_at", "created_at")},
+}
+
+// migrationLockTimeout bounds how long a replica waits for another replica
+// that is migrating the same database
+const migrationLockTimeout = 60
+
+// execStatements returns a migration step running the statements in order,
+// with {prefix} expanded to the table prefix
+func execStatements(statements ...string) func(tx *sql.Tx, prefix string) error {
+	return func(tx *sql.Tx, prefix string) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(strings.ReplaceAll(statement, "{prefix}", prefix)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addIndex returns a migration step creating an index unless it already
+// exists. MySQL has no CREATE INDEX IF NOT EXISTS.
+func addIndex(table, index, columns string) func(tx *sql.Tx, prefix string) error {
+	return func(tx *sql.Tx, prefix string) error {
+		table := prefix + table
+		var count int
+		err := tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?;`,
+			table, index).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		_, err = tx.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (%s);", index, table, columns))
+		return err
+	}
+}
+
+// Migrate applies all pending migrations. Each migration runs in its own
+// transaction together with its schema_migrations row. Note that MySQL
+// commits DDL implicitly, so a failing multi-statement migration can leave
+// earlier statements applied; keep each migration to one logical change.
+// A named lock serializes replicas that start at the same time.
+func (db *DB) Migrate(ctx context.Context) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting migration connection: %v", err)
+	}
+	defer conn.Close()
+
+	// The lock name carries the prefix too, so deployments sharing a schema
+	// don't wait on each other's migrations
+	lockName := db.tables("{prefix}schema_migrations")
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?);", lockName, migrationLockTimeout).Scan(&locked); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %v", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for migration lock")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?);", lockName)
+
+	_, err = conn.ExecContext(ctx, db.tables(`
+	CREATE TABLE IF NOT EXISTS {prefix}schema_migrations (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`))
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, db.tables("SELECT version FROM {prefix}schema_migrations;"))
+	if err != nil {
+		return fmt.Errorf("error reading applied migrations: %v", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("error reading applied migrations: %v", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading applied migrations: %v", err)
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error starting migration %04d_%s: %v", m.version, m.name, err)
+		}
+		if err := m.up(tx, db.TablePrefix); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %04d_%s: %v", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(db.tables("INSERT INTO {prefix}schema_migrations (version, name) VALUES (?, ?);"), m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error recording migration %04d_%s: %v", m.version, m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing migration %04d_%s: %v", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
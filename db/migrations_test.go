@@ -0,0 +1,32 @@
+package db
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	namePattern := regexp.MustCompile(`^[a-z0-9_]+$`)
+	names := make(map[string]bool)
+
+	for i, m := range migrations {
+		// Versions start at 1 and leave no gaps, so a missing migration
+		// can't go unnoticed
+		if m.version != i+1 {
+			t.Errorf("migration %d (%s) has version %d, want %d", i, m.name, m.version, i+1)
+		}
+		if !namePattern.MatchString(m.name) {
+			t.Errorf("migration %d name %q is not snake_case", m.version, m.name)
+		}
+		if names[m.name] {
+			t.Errorf("migration name %q is used twice", m.name)
+		}
+		names[m.name] = true
+		if m.up == nil {
+			t.Errorf("migration %d (%s) has no up step", m.version, m.name)
+		}
+	}
+	if len(migrations) == 0 || migrations[0].name != "initial_schema" {
+		t.Error("the first migration must be initial_schema")
+	}
+}
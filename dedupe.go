@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// recentLookups remembers provider answers for a few seconds, so a
+// double-submitted form or a client retrying too eagerly gets the answer
+// it just paid for instead of paying again. Unlike the database cache it
+// also covers numbers with no name, which are never cached. A nil
+// *recentLookups remembers nothing.
+type recentLookups struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]recentLookup
+}
+
+type recentLookup struct {
+	outcome lookupOutcome
+	expires time.Time
+}
+
+// newRecentLookups returns lookups remembered for window, or nil when
+// window is 0
+func newRecentLookups(window time.Duration) *recentLookups {
+	if window <= 0 {
+		return nil
+	}
+	return &recentLookups{window: window, entries: make(map[string]recentLookup)}
+}
+
+// recentLookupKey identifies a repeat of the same lookup. Tenants are kept
+// apart since each pays for its own calls, and a verification is only a
+// repeat if it checks the same name.
+func recentLookupKey(ctx context.Context, mobile, verifyName string) string {
+	return tenantFromContext(ctx) + "\x00" + mobile + "\x00" + verifyName
+}
+
+// Get returns a copy of the outcome remembered under key, if it is still
+// within the window
+func (r *recentLookups) Get(key string) (*lookupOutcome, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	outcome := entry.outcome
+	return &outcome, true
+}
+
+// Put remembers outcome under key for the window. Expired entries are
+// dropped on the way; with a window of seconds there are never many.
+func (r *recentLookups) Put(key string, outcome *lookupOutcome) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range r.entries {
+		if now.After(entry.expires) {
+			delete(r.entries, k)
+		}
+	}
+	r.entries[key] = recentLookup{outcome: *outcome, expires: now.Add(r.window)}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the hand-written contract for the JSON API. Keep it in
+// step with the /api/v1 routes.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// docsPage renders openapi.yaml with Swagger UI. The spec URL is relative
+// so the page keeps working under ROUTE_PREFIX.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+    <title>Mobile Name Lookup API</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+        window.onload = function() {
+            SwaggerUIBundle({url: "openapi.yaml", dom_id: "#swagger-ui"});
+        };
+    </script>
+</body>
+</html>
+`
+
+// openAPIHandler serves GET /openapi.yaml
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Write(openAPISpec)
+}
+
+// docsHandler serves the Swagger UI page at GET /docs
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type schemeKey struct{}
+
+// Middleware taking the request scheme from X-Forwarded-Proto. Only install
+// it behind a proxy that sets the header itself, since clients can send any
+// value; a TLS-terminating proxy otherwise makes every request look like
+// plain HTTP.
+func forwardedProtoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A chain of proxies sends a list; the first entry is the one the
+		// client connected with
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		proto = strings.ToLower(strings.TrimSpace(proto))
+		if proto == "http" || proto == "https" {
+			r = r.WithContext(context.WithValue(r.Context(), schemeKey{}, proto))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestScheme returns the scheme the client used: the one stored by
+// forwardedProtoMiddleware, else https when we terminated TLS ourselves
+func requestScheme(r *http.Request) string {
+	if scheme, ok := r.Context().Value(schemeKey{}).(string); ok {
+		return scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// absoluteURL builds the URL of path on the host the client asked for
+func absoluteURL(r *http.Request, path string) string {
+	return requestScheme(r) + "://" + r.Host + path
+}
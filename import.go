@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// importProblem is a CSV line that was skipped, reported with its 1-based
+// line number
+type importProblem struct {
+	Line    int    `json:"line"`
+	Mobile  string `json:"mobile,omitempty"`
+	Message string `json:"message"`
+	// FirstLine is the line that was imported instead of a duplicate
+	FirstLine int `json:"first_line,omitempty"`
+}
+
+// importRow is a valid CSV row ready to be saved
+type importRow struct {
+	Line   int
+	Mobile string
+	Name   string
+	// RawMobile is the number as it appeared in the file
+	RawMobile string
+}
+
+// parseImportCSV reads mobile,name rows, skipping a leading header row.
+// Numbers are normalized before the duplicate check, so +91 83180 90007 and
+// 8318090007 collide; the first occurrence wins and later ones are reported
+// with the line it is on. Rows come back in file order.
+func parseImportCSV(body io.Reader) ([]importRow, []importProblem, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []importRow
+	var problems []importProblem
+	firstLine := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("line %d: %v", parseErr.Line, parseErr.Err)
+			}
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if line == 1 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "mobile") {
+			continue
+		}
+		if len(record) < 2 {
+			problems = append(problems, importProblem{Line: line, Message: "expected mobile,name"})
+			continue
+		}
+
+		mobile, err := cleanPhoneNumber(record[0])
+		if err != nil {
+			problems = append(problems, importProblem{Line: line, Message: fmt.Sprintf("Invalid mobile number: %v", err)})
+			continue
+		}
+		name := strings.TrimSpace(record[1])
+		if name == "" {
+			problems = append(problems, importProblem{Line: line, Mobile: mobile, Message: "name is required"})
+			continue
+		}
+		if first, ok := firstLine[mobile]; ok {
+			problems = append(problems, importProblem{Line: line, Mobile: mobile, Message: "duplicate number", FirstLine: first})
+			continue
+		}
+
+		firstLine[mobile] = line
+		rows = append(rows, importRow{Line: line, Mobile: mobile, Name: name, RawMobile: record[0]})
+	}
+	return rows, problems, nil
+}
+
+// importHandler serves POST /api/v1/import for admins, caching the names in
+// a CSV body of mobile,name rows. Invalid and duplicate rows are skipped and
+// listed under "skipped" rather than failing the whole file. With
+// storeRawMobile set the numbers are also kept as they appear in the file.
+func importHandler(database Store, storeRawMobile bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		rows, problems, err := parseImportCSV(r.Body)
+		if err != nil {
+			if bodyTooLarge(err) {
+				respondBodyTooLarge(w, r)
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("Invalid CSV: %v", err))
+			return
+		}
+
+		imported := 0
+		for _, row := range rows {
+			rawMobile := ""
+			if storeRawMobile {
+				rawMobile = row.RawMobile
+			}
+			if err := database.SaveMobileRecord(r.Context(), row.Mobile, row.Name, rawMobile); err != nil {
+				// Past the deadline every remaining row would fail too.
+				// Saves are upserts, so the file can simply be sent again.
+				if errors.Is(err, context.DeadlineExceeded) {
+					logger.WithField("imported", imported).Warn("Import timed out")
+					auditAdminAction(database, r, auditImported, "", fmt.Sprintf("imported=%d timed_out_at_line=%d", imported, row.Line))
+					writeJSONError(w, http.StatusGatewayTimeout, errCodeTimeout, fmt.Sprintf("Timed out after importing %d rows; send the file again to import the rest", imported))
+					return
+				}
+				logger.WithError(err).WithField("line", row.Line).Error("Failed to import record")
+				problems = append(problems, importProblem{Line: row.Line, Mobile: row.Mobile, Message: "Database error occurred"})
+				continue
+			}
+			imported++
+		}
+		if problems == nil {
+			problems = []importProblem{}
+		}
+		sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+
+		logger.WithFields(logrus.Fields{
+			"imported": imported,
+			"skipped":  len(problems),
+			"ip":       r.RemoteAddr,
+		}).Warn("Imported cache records")
+		auditAdminAction(database, r, auditImported, "", fmt.Sprintf("imported=%d skipped=%d", imported, len(problems)))
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"imported": imported,
+			"skipped":  problems,
+		})
+	}
+}
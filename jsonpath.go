@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultNameJSONPath is where Digitap puts the name in its response
+const defaultNameJSONPath = "result.mobile_linked_name"
+
+// validJSONPath checks a dotted path such as data.fullName or
+// data.names.0: object keys, or array indexes, separated by dots
+func validJSONPath(path string) error {
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			return fmt.Errorf("%q has an empty segment", path)
+		}
+	}
+	return nil
+}
+
+// lookupJSONPath walks body along a dotted path, decoding only the values
+// on the way. Numeric segments index into arrays. It reports false when a
+// segment is missing or the value there has the wrong type.
+func lookupJSONPath(body []byte, path string) (json.RawMessage, bool) {
+	value := json.RawMessage(body)
+	for _, segment := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err == nil {
+			next, ok := object[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+			continue
+		}
+
+		var array []json.RawMessage
+		index, err := strconv.Atoi(segment)
+		if err != nil || json.Unmarshal(value, &array) != nil || index < 0 || index >= len(array) {
+			return nil, false
+		}
+		value = array[index]
+	}
+	return value, true
+}
+
+// jsonPathString returns the string at path in body, or "" when the path
+// is missing or doesn't hold a string
+func jsonPathString(body []byte, path string) string {
+	value, ok := lookupJSONPath(body, path)
+	if !ok {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mobile-name-lookup/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sources reported for a resolved lookup
+const (
+	sourceOverride = "override"
+	sourceDatabase = "database"
+	sourceAPI      = "api"
+	sourceDryRun   = "dry_run"
+)
+
+// sourceError labels the latency of lookups that failed
+const sourceError = "error"
+
+const dryRunMessage = "DRY RUN: would call API"
+
+// mobileLockTimeout bounds how long a lookup waits for another request
+// resolving the same number
+const mobileLockTimeout = 10 * time.Second
+
+// lookupInput is a single number to resolve
+type lookupInput struct {
+	Mobile     string // as entered by the caller
+	VerifyName string
+	ClientRef  string
+	DryRun     bool
+	// Refresh skips the cache read and always calls the provider
+	Refresh    bool
+	RemoteAddr string
+}
+
+// lookupOutcome describes how a number was resolved
+type lookupOutcome struct {
+	Mobile    string // cleaned 10-digit number
+	Name      string
+	Source    string
+	ClientRef string
+	Result    *LookupResult // provider answer, set when Source is sourceAPI
+	// Candidates lists every possible name, Name first, when the
+	// provider returned more than one
+	Candidates []string
+
+	// Match and MatchScore are our own comparison of the provider's name
+	// with the name to verify, set in strict verification mode
+	Match      *bool
+	MatchScore *float64
+
+	// CacheBypassed is set when the database failed and the provider
+	// answered without the cache in degraded mode
+	CacheBypassed bool
+
+	// Placeholder is shown in place of the name when the provider found
+	// none and DEFAULT_UNKNOWN_NAME is set; it is never cached
+	Placeholder string
+
+	// CachedAt is when the cached record was last written, set when
+	// Source is sourceDatabase
+	CachedAt time.Time
+}
+
+// lookupError is a failed lookup with the status and code to report
+type lookupError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *lookupError) Error() string {
+	return e.Message
+}
+
+// lookupService resolves numbers through the overrides, the DB cache and the
+// lookup providers, in that order
+type lookupService struct {
+	database         Store
+	provider         NameLookupProvider
+	notifier         *WebhookNotifier
+	stats            *LookupStats
+	rejectSuspicious bool
+	dryRunMode       bool
+	nameMode         string
+	minConfidence    float64
+	batchConcurrency int
+	alwaysFresh      bool
+
+	// strictVerify compares the provider's name with the name to verify
+	// ourselves, matching at a token sort ratio of at least matchThreshold
+	strictVerify   bool
+	matchThreshold float64
+
+	// logSampleRate is the fraction of successful provider responses
+	// saved to api_response_logs; errors are always saved
+	logSampleRate float64
+
+	// degradeOnDBError serves lookups straight from the provider when
+	// the database can't be read, instead of failing them
+	degradeOnDBError bool
+
+	// tenants supplies the provider for requests carrying X-Tenant; nil
+	// when no tenants are configured
+	tenants *TenantResolver
+
+	// unknownName, when set, is returned instead of an empty name
+	unknownName string
+
+	// storeRawMobile saves the number as the caller typed it with each
+	// cached name
+	storeRawMobile bool
+	// recent answers repeats of a lookup the provider just answered;
+	// nil when DEDUPE_WINDOW_SECONDS is 0
+	recent *recentLookups
+
+	// nameBlocklist holds provider placeholder names treated as no name
+	nameBlocklist *nameBlocklist
+
+	// responseLogs, when set, saves api_response_logs rows in the
+	// background instead of during the request
+	responseLogs *responseLogWriter
+
+	// readOnly serves overrides and cached names only; anything else is
+	// answered with errCodeReadOnly instead of calling the provider
+	readOnly bool
+}
+
+// Resolve validates and resolves one number
+func (s *lookupService) Resolve(ctx context.Context, in lookupInput) (*lookupOutcome, *lookupError) {
+	if in.Mobile == "" {
+		return nil, &lookupError{http.StatusBadRequest, errCodeEmptyMobile, "Mobile number is required"}
+	}
+
+	// Clean and validate mobile number
+	mobile, err := cleanPhoneNumber(in.Mobile)
+	if err != nil {
+		return nil, &lookupError{http.StatusBadRequest, mobileErrorCode(err), fmt.Sprintf("Invalid mobile number: %v", err)}
+	}
+
+	if s.rejectSuspicious && !isPlausibleNumber(mobile) {
+		return nil, &lookupError{http.StatusBadRequest, errCodeInvalidMobile, "Invalid mobile number: number looks fake (repeated or sequential digits)"}
+	}
+
+	// Every valid number counts against the caller's daily quota, however
+	// it ends up answered
+	if charge := quotaChargeFromContext(ctx); charge != nil && !charge.take(ctx, time.Now().UTC()) {
+		return nil, &lookupError{http.StatusTooManyRequests, errCodeQuotaExceeded, charge.quota.message()}
+	}
+
+	// Callers may pass their own reference; otherwise derive a stable
+	// one so retries of the same number on the same day correlate
+	clientRef := in.ClientRef
+	if clientRef == "" {
+		clientRef = deriveClientRef(mobile, time.Now())
+	} else if !validClientRef(clientRef) {
+		return nil, &lookupError{http.StatusBadRequest, errCodeInvalidRequest, "client_ref_num must be 1-64 letters, digits, underscores or hyphens"}
+	}
+
+	// Dry runs go through validation and the DB check but never call
+	// the paid API or write to the database
+	dryRun := s.dryRunMode || in.DryRun
+
+	// Log request
+	logger.WithFields(logrus.Fields{
+		"raw_mobile":   redactMobile(in.Mobile),
+		"clean_mobile": redactMobile(mobile),
+		"mobile_hash":  hashMobile(mobile),
+		"ip":           in.RemoteAddr,
+		"dry_run":      dryRun,
+		"client_ref":   clientRef,
+		"tenant":       tenantFromContext(ctx),
+	}).Info("Lookup request received")
+	s.stats.total.Add(1)
+
+	outcome := &lookupOutcome{Mobile: mobile, ClientRef: clientRef}
+
+	// An optional name asks the provider whether it belongs to the
+	// number. Only the provider can score that, so verification
+	// requests skip the override and cache reads.
+	verifyName := cleanVerifyName(in.VerifyName)
+	verifying := verifyName != ""
+
+	// In always-fresh mode cached names are never served. Results are
+	// still saved, so the cache is warm if the mode is switched off.
+	// Only names are ever cached, so there are no cached not-found
+	// results that could still be served.
+	// Read-only mode has nothing but the cache to serve from, so it
+	// ignores both.
+	readCache := !verifying && (s.readOnly || !s.alwaysFresh && !in.Refresh)
+	if in.Refresh {
+		logger.WithFields(mobileLogFields(mobile)).
+			WithField("ip", in.RemoteAddr).
+			Warn("Forced refresh; skipping the cache")
+	}
+
+	// Manual overrides win over both the cache and the API. A database
+	// read cut short by the request deadline is never degraded past, as
+	// there is no time left for the provider either.
+	var override *db.Override
+	if !verifying {
+		override, err = s.database.GetOverride(ctx, mobile)
+	}
+	if err != nil {
+		if !s.degradeOnDBError || errors.Is(err, context.DeadlineExceeded) {
+			logger.WithError(err).Error("Failed to query overrides")
+			return nil, databaseError(err)
+		}
+		s.bypassCache(outcome, err)
+		err = nil
+	}
+
+	if override != nil {
+		logger.WithFields(mobileLogFields(mobile)).Info("Using manual override")
+		s.stats.overrides.Add(1)
+
+		outcome.Name, outcome.Source = override.Name, sourceOverride
+		return outcome, nil
+	}
+
+	// Next, check if we have the record in our database
+	var record *db.MobileRecord
+	if readCache && !outcome.CacheBypassed {
+		record, err = s.database.GetMobileRecord(ctx, mobile)
+	}
+	if err != nil {
+		if !s.degradeOnDBError || errors.Is(err, context.DeadlineExceeded) {
+			logger.WithError(err).Error("Failed to query database")
+			return nil, databaseError(err)
+		}
+		s.bypassCache(outcome, err)
+	}
+
+	if record != nil {
+		return s.cacheHit(outcome, record), nil
+	}
+
+	if s.readOnly {
+		logger.WithFields(mobileLogFields(mobile)).Info("Read-only mode: not looking up uncached number")
+		s.stats.readOnlyMisses.Add(1)
+		return nil, &lookupError{http.StatusServiceUnavailable, errCodeReadOnly, readOnlyMessage}
+	}
+
+	// Only one request across all replicas resolves a given number at a
+	// time (see db.LockMobile). A request that waited re-reads the cache,
+	// which the lock holder has usually just filled. If the lock can't be
+	// taken the lookup goes ahead unlocked rather than failing.
+	if readCache && !dryRun && !outcome.CacheBypassed {
+		unlock, err := s.database.LockMobile(ctx, mobile, mobileLockTimeout)
+		if err != nil {
+			logger.WithError(err).WithFields(mobileLogFields(mobile)).Warn("Resolving without the lookup lock")
+		} else {
+			defer unlock()
+
+			// The lock holder wrote to the primary; a replica may not
+			// have the name yet
+			record, err := s.database.GetMobileRecordFromPrimary(ctx, mobile)
+			if err != nil {
+				if !s.degradeOnDBError || errors.Is(err, context.DeadlineExceeded) {
+					logger.WithError(err).Error("Failed to query database")
+					return nil, databaseError(err)
+				}
+				s.bypassCache(outcome, err)
+			}
+			if record != nil {
+				return s.cacheHit(outcome, record), nil
+			}
+		}
+	}
+
+	if dryRun {
+		logger.WithFields(mobileLogFields(mobile)).
+			WithField("dry_run", true).
+			Info("Dry run: skipping provider lookup")
+
+		outcome.Source = sourceDryRun
+		return outcome, nil
+	}
+
+	// A repeat of a lookup the provider answered seconds ago, such as a
+	// double-submitted form, gets the same answer without a second call.
+	// Lookups that bypass the cache on purpose bypass this too.
+	dedupe := !s.alwaysFresh && !in.Refresh
+	recentKey := recentLookupKey(ctx, mobile, verifyName)
+	if recent, ok := s.recent.Get(recentKey); dedupe && ok {
+		logger.WithFields(mobileLogFields(mobile)).
+			WithField("client_ref", recent.ClientRef).
+			Info("Repeat lookup answered from the dedupe window")
+		s.stats.deduped.Add(1)
+		return recent, nil
+	}
+
+	// If not in database, query the lookup providers. Latency covers
+	// every retry and failover attempt.
+	start := time.Now()
+	result, err := s.providerFor(ctx).Lookup(ctx, LookupRequest{
+		Mobile:    mobile,
+		Name:      verifyName,
+		ClientRef: clientRef,
+	})
+	latencyMs := time.Since(start).Milliseconds()
+	if err != nil {
+		logger.WithError(err).WithFields(mobileLogFields(mobile)).
+			WithField("client_ref", clientRef).
+			Error("Lookup failed")
+
+		// No call was made, so there is nothing to log
+		if errors.Is(err, errProviderBusy) {
+			return nil, &lookupError{http.StatusServiceUnavailable, errCodeProviderBusy, "Too many lookups in progress. Please try again."}
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &lookupError{http.StatusGatewayTimeout, errCodeTimeout, "Lookup timed out. Please try again."}
+		}
+		entry := &db.APIResponseLog{Mobile: mobile, Status: "error", LatencyMs: latencyMs}
+		logged := map[string]interface{}{"error": err.Error()}
+		if perr, ok := err.(*ProviderError); ok {
+			entry.Provider, entry.StatusCode = perr.Provider, perr.StatusCode
+			if perr.Body != "" {
+				logged["raw_body"] = perr.Body
+			}
+		}
+		s.saveResponseLog(outcome, entry, logged)
+
+		if errors.Is(err, ErrBadProviderResponse) {
+			return nil, &lookupError{http.StatusBadGateway, errCodeBadResponse, "The lookup service sent a response we couldn't read. Please try again later."}
+		}
+		return nil, &lookupError{http.StatusInternalServerError, errCodeUpstream, "Service temporarily unavailable. Please try again."}
+	}
+
+	// Keep the provider's raw name in the response log, then store
+	// and return the normalized form
+	rawFields := result.Fields
+	if rawFields == nil {
+		rawFields = map[string]interface{}{
+			"mobile_linked_name": result.Name,
+			"name_match":         result.NameMatch,
+			"name_match_score":   result.NameMatchScore,
+		}
+		if result.Candidates != nil {
+			rawFields["candidate_names"] = result.Candidates
+		}
+	}
+	s.saveResponseLog(outcome, &db.APIResponseLog{
+		Mobile:     mobile,
+		Status:     "success",
+		Provider:   result.Provider,
+		StatusCode: result.StatusCode,
+		LatencyMs:  latencyMs,
+	}, rawFields)
+	name := s.acceptedName(mobile, result)
+
+	s.stats.apiHits.Add(1)
+	if name == "" {
+		s.stats.notFound.Add(1)
+		outcome.Placeholder = s.unknownName
+	}
+
+	// If we got a name from the API, save it to our database and
+	// notify the webhook receiver about the new mapping. A database
+	// that just failed is not written to.
+	if name != "" && !outcome.CacheBypassed {
+		rawMobile := ""
+		if s.storeRawMobile {
+			rawMobile = in.Mobile
+		}
+		// The call has been paid for, so the name is saved even if the
+		// request's deadline runs out meanwhile
+		if err := s.database.SaveMobileRecord(context.WithoutCancel(ctx), mobile, name, rawMobile); err != nil {
+			logger.WithError(err).Error("Failed to save record to database")
+		} else {
+			s.notifier.Notify(requestIDFromContext(ctx), WebhookEvent{
+				Mobile:     mobile,
+				Name:       name,
+				ResolvedAt: time.Now().UTC(),
+			})
+		}
+	}
+
+	logger.WithFields(mobileLogFields(mobile)).
+		WithField("status", "success").
+		WithField("client_ref", clientRef).
+		Info("Lookup successful")
+
+	outcome.Name, outcome.Source, outcome.Result = name, sourceAPI, result
+	if name != "" {
+		for _, candidate := range result.Candidates {
+			outcome.Candidates = append(outcome.Candidates, normalizeName(candidate, s.nameMode))
+		}
+	}
+
+	// In strict mode the verdict is ours rather than the provider's, so
+	// it is consistent across providers
+	if verifying && s.strictVerify {
+		score := 0.0
+		if name != "" {
+			score = tokenSortRatio(name, verifyName)
+		}
+		match := score >= s.matchThreshold
+		outcome.Match, outcome.MatchScore = &match, &score
+	}
+
+	if dedupe {
+		s.recent.Put(recentKey, outcome)
+	}
+	return outcome, nil
+}
+
+// acceptedName is the provider's name as it would be cached: normalized,
+// and empty when the provider wasn't confident enough. Rather than cache a
+// doubtful name, low-confidence results are treated as not found; results
+// without a score always pass.
+func (s *lookupService) acceptedName(mobile string, result *LookupResult) string {
+	name := normalizeName(result.Name, s.nameMode)
+	if name != "" && s.nameBlocklist.Blocked(name) {
+		logger.WithFields(mobileLogFields(mobile)).
+			WithField("name", name).
+			Warn("Discarding placeholder name from the provider")
+		return ""
+	}
+	if confidence, ok := result.Confidence(); ok && name != "" && confidence < s.minConfidence {
+		logger.WithFields(mobileLogFields(mobile)).WithFields(logrus.Fields{
+			"name":           name,
+			"confidence":     confidence,
+			"min_confidence": s.minConfidence,
+		}).Warn("Discarding low-confidence provider result")
+		return ""
+	}
+	return name
+}
+
+// providerFor returns the provider for the request's tenant, or the global
+// provider when the request has none. The cache is shared by all tenants,
+// since a number's name doesn't depend on the account that looked it up.
+func (s *lookupService) providerFor(ctx context.Context) NameLookupProvider {
+	if tenant := tenantFromContext(ctx); tenant != "" && s.tenants != nil {
+		if provider := s.tenants.Provider(tenant); provider != nil {
+			return provider
+		}
+	}
+	return s.provider
+}
+
+// bypassCache marks outcome as resolved without the database after a read
+// failed in degraded mode
+func (s *lookupService) bypassCache(outcome *lookupOutcome, err error) {
+	logger.WithError(err).WithFields(mobileLogFields(outcome.Mobile)).
+		Warn("Database unavailable; bypassing the cache")
+	outcome.CacheBypassed = true
+}
+
+// cacheHit completes outcome from a cached record
+func (s *lookupService) cacheHit(outcome *lookupOutcome, record *db.MobileRecord) *lookupOutcome {
+	logger.WithFields(mobileLogFields(outcome.Mobile)).
+		WithField("name", record.Name).
+		Info("Found record in database")
+	s.stats.dbHits.Add(1)
+
+	outcome.Name, outcome.Source = record.Name, sourceDatabase
+	outcome.CachedAt = record.UpdatedAt
+	return outcome
+}
+
+// saveResponseLog records a provider response with result encoded as JSON;
+// failures are logged but never fail the lookup. Successful responses are
+// only kept for a logSampleRate fraction of calls, and nothing is written
+// once outcome has bypassed a failing database.
+func (s *lookupService) saveResponseLog(outcome *lookupOutcome, entry *db.APIResponseLog, result interface{}) {
+	if outcome.CacheBypassed {
+		return
+	}
+	if entry.Status == "success" && s.logSampleRate < 1 && rand.Float64() >= s.logSampleRate {
+		return
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		logger.WithError(err).Error("Failed to encode API response log")
+		return
+	}
+	entry.Result = string(encoded)
+	if s.responseLogs != nil {
+		s.responseLogs.Save(entry)
+		return
+	}
+	if err := s.database.SaveAPIResponseLog(context.Background(), entry); err != nil {
+		logger.WithError(err).Error("Failed to save API response log")
+	}
+}
+
+// notFound reports whether the number resolved without a name, whether
+// from the cache or a live provider call. Dry runs never resolve a name
+// and don't count.
+func (o *lookupOutcome) notFound() bool {
+	return o.Name == "" && o.Source != sourceDryRun
+}
+
+// displayName is the name to show callers: the placeholder when none was
+// found and one is configured
+func (o *lookupOutcome) displayName() string {
+	if o.Name == "" {
+		return o.Placeholder
+	}
+	return o.Name
+}
+
+// cacheAgeSeconds is how long ago the cached name was written, 0 for a
+// live provider answer
+func (o *lookupOutcome) cacheAgeSeconds() int64 {
+	if o.Source != sourceDatabase || o.CachedAt.IsZero() {
+		return 0
+	}
+	age := int64(time.Since(o.CachedAt).Seconds())
+	if age < 0 {
+		// Clock skew between us and the database
+		return 0
+	}
+	return age
+}
+
+// apiJSON is the JSON API body for a resolved lookup
+func (o *lookupOutcome) apiJSON() map[string]interface{} {
+	result := map[string]interface{}{
+		"mobile_linked_name": o.displayName(),
+		"mobile":             o.Mobile,
+	}
+	body := map[string]interface{}{
+		"status": "success",
+		"result": result,
+		"source": o.Source,
+	}
+
+	if o.CacheBypassed {
+		body["cache_bypassed"] = true
+	}
+	if o.Source != sourceDryRun {
+		result["name_found"] = !o.notFound()
+	}
+
+	switch o.Source {
+	case sourceDryRun:
+		body["status"] = "dry_run"
+		body["message"] = dryRunMessage
+	case sourceDatabase:
+		body["cache_age_seconds"] = o.cacheAgeSeconds()
+	case sourceAPI:
+		body["message"] = ""
+		body["cache_age_seconds"] = 0
+		body["client_ref_num"] = o.ClientRef
+		result["name_match"] = o.Result.NameMatch
+		result["name_match_score"] = o.Result.NameMatchScore
+		if o.Candidates != nil {
+			result["candidate_names"] = o.Candidates
+		}
+
+		if o.Match != nil {
+			result["match"] = *o.Match
+			result["match_score"] = *o.MatchScore
+		}
+
+		// Pass through any additional provider fields without
+		// replacing the ones clients already read
+		for key, value := range o.Result.Fields {
+			if _, exists := result[key]; !exists {
+				result[key] = value
+			}
+		}
+	}
+
+	return body
+}
+
+// pageData is the HTML template data for a resolved lookup
+func (o *lookupOutcome) pageData() PageData {
+	switch {
+	case o.Source == sourceDryRun:
+		return PageData{Result: &MobileNameLookupResponse{Status: "dry_run", Message: dryRunMessage}}
+	case o.Result == nil && o.Name != "":
+		return PageData{Record: &db.MobileRecord{Mobile: o.Mobile, Name: o.Name}}
+	}
+
+	// Provider answers and empty cache entries; the template shows
+	// "No name found" when the name is empty
+	response := &MobileNameLookupResponse{Status: "success"}
+	response.Result.MobileLinkedName = o.displayName()
+	if o.Result != nil {
+		response.Result.NameMatch = o.Result.NameMatch
+		response.Result.NameMatchScore = o.Result.NameMatchScore
+	}
+	data := PageData{Result: response, Match: o.Match, MatchScore: o.MatchScore}
+	if len(o.Candidates) > 1 {
+		data.OtherNames = o.Candidates[1:]
+	}
+	return data
+}
+
+// renderFunc renders the HTML page with data
+type renderFunc func(w http.ResponseWriter, r *http.Request, data PageData)
+
+// newLookupHandler serves lookups for the HTML form (/lookup_post) and the
+// JSON API (/api/v1/lookup). Forced refreshes make a paid call even for
+// cached numbers, so API callers need one of adminKeys to ask for one.
+func newLookupHandler(service *lookupService, render renderFunc, routePrefix string, adminKeys []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var mobile, clientRef, verifyName string
+		var refresh bool
+
+		switch r.Method {
+		case http.MethodGet:
+			if !isAPIPath(r) {
+				// Redirect GET requests to home page
+				http.Redirect(w, r, absoluteURL(r, routePrefix+"/"), http.StatusSeeOther)
+				return
+			}
+
+			// Integrations that can't POST pass the JSON body fields as
+			// query parameters instead
+			query := r.URL.Query()
+			mobile = query.Get("mobile")
+			clientRef = query.Get("client_ref_num")
+			verifyName = query.Get("name")
+			refresh, _ = strconv.ParseBool(query.Get("refresh"))
+		case http.MethodPost:
+			// Check if it's a JSON request
+			if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+				var requestBody struct {
+					Mobile    string `json:"mobile"`
+					Name      string `json:"name"`
+					ClientRef string `json:"client_ref_num"`
+					Refresh   bool   `json:"refresh"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+					logger.WithError(err).Error("Failed to decode JSON body")
+					if bodyTooLarge(err) {
+						respondBodyTooLarge(w, r)
+					} else if isAPIRequest(r) {
+						respondWithAPIError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Invalid JSON body")
+					} else {
+						http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+					}
+					return
+				}
+				mobile = requestBody.Mobile
+				clientRef = requestBody.ClientRef
+				verifyName = requestBody.Name
+				refresh = requestBody.Refresh
+			} else {
+				// Handle form data
+				if err := r.ParseForm(); err != nil {
+					logger.WithError(err).Error("Failed to parse form")
+					if bodyTooLarge(err) {
+						respondBodyTooLarge(w, r)
+					} else if isAPIRequest(r) {
+						respondWithAPIError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Invalid form data")
+					} else {
+						http.Error(w, "Invalid form data", http.StatusBadRequest)
+					}
+					return
+				}
+				if !isAPIRequest(r) && !validCSRFToken(r) {
+					logger.WithField("ip", r.RemoteAddr).Warn("Rejected form submission with invalid CSRF token")
+					http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+					return
+				}
+				mobile = r.FormValue("mobile")
+				verifyName = r.FormValue("name")
+				refresh, _ = strconv.ParseBool(r.FormValue("refresh"))
+
+				// The textarea form sends one number per line; a
+				// single line is treated like the single-number form
+				if lines := splitMobileLines(r.FormValue("mobiles")); len(lines) == 1 {
+					mobile = lines[0]
+				} else if len(lines) > 1 {
+					if len(lines) > maxBatchSize {
+						render(w, r, PageData{Error: fmt.Sprintf("Enter at most %d numbers at a time", maxBatchSize)})
+						return
+					}
+
+					dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryrun"))
+					items := service.ResolveBatch(r.Context(), lines, dryRun, r.RemoteAddr)
+					rows := make([]batchRow, len(items))
+					for i, item := range items {
+						rows[i] = item.row()
+					}
+					render(w, r, PageData{Batch: rows})
+					return
+				}
+			}
+		default:
+			if isAPIRequest(r) {
+				respondWithAPIError(w, r, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if refresh && isAPIRequest(r) && !validAPIKey(r.Header.Get("X-API-Key"), adminKeys) {
+			logger.WithField("ip", r.RemoteAddr).Warn("Rejected forced refresh without an admin key")
+			respondWithAPIError(w, r, http.StatusForbidden, errCodeForbidden, "refresh requires an admin API key")
+			return
+		}
+
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryrun"))
+
+		// The form shows the name as it was sent to the provider
+		verifyName = cleanVerifyName(verifyName)
+
+		outcome, lerr := service.Resolve(r.Context(), lookupInput{
+			Mobile:     mobile,
+			VerifyName: verifyName,
+			ClientRef:  clientRef,
+			DryRun:     dryRun,
+			Refresh:    refresh,
+			RemoteAddr: r.RemoteAddr,
+		})
+
+		// Latency is observed once the response is written, by the
+		// source that served it
+		source := sourceError
+		if lerr == nil {
+			source = outcome.Source
+		}
+		defer func() { service.stats.latency.Observe(source, time.Since(start)) }()
+
+		if lerr != nil {
+			if isAPIRequest(r) {
+				respondWithAPIError(w, r, lerr.Status, lerr.Code, lerr.Message)
+			} else {
+				render(w, r, PageData{Error: lerr.Message, VerifyName: verifyName})
+			}
+			return
+		}
+
+		// Only /api/ answers a miss with 404; legacy JSON clients of
+		// /lookup_post keep their 200 with an empty name
+		if isAPIPath(r) && outcome.notFound() && outcome.Placeholder == "" {
+			respondWithAPIError(w, r, http.StatusNotFound, errCodeNotFound, "No name found for this number")
+			return
+		}
+
+		if outcome.CacheBypassed {
+			w.Header().Set("X-Cache-Bypassed", "true")
+		}
+		switch {
+		case isAPIPath(r) && wantsXML(r):
+			respondWithXML(w, http.StatusOK, outcome.apiXML())
+		case isAPIRequest(r):
+			respondWithJSON(w, http.StatusOK, outcome.apiJSON())
+		default:
+			data := outcome.pageData()
+			data.VerifyName = verifyName
+			render(w, r, data)
+		}
+	}
+}
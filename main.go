@@ -1,23 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"mobile-name-lookup/db"
 
 	"github.com/joho/godotenv"
-	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 )
@@ -28,14 +35,91 @@ type MobileNameLookupResponse struct {
 	Message string `json:"message"`
 	Result  struct {
 		MobileLinkedName string `json:"mobile_linked_name"`
+		// Every possible name, when the provider is unsure which one
+		// the number belongs to
+		CandidateNames []string `json:"candidate_names,omitempty"`
+		// Only returned when a name to verify was sent with the request
+		NameMatch      *bool    `json:"name_match,omitempty"`
+		NameMatchScore *float64 `json:"name_match_score,omitempty"`
 	} `json:"result"`
+	// ResultFields holds every field of "result" as returned, including ones
+	// without a typed field above
+	ResultFields map[string]interface{} `json:"-"`
+	// StatusCode is the HTTP status the response arrived with
+	StatusCode int `json:"-"`
 }
 
 // DigitapClient handles API communication
 type DigitapClient struct {
+	// Name identifies this client in response logs (default "digitap")
+	Name       string
 	BaseURL    string
 	AuthToken  string
 	HTTPClient *http.Client
+	// Breaker, if set, fast-fails lookups while the API keeps failing
+	Breaker *CircuitBreaker
+	// Calls, if set, caps concurrent calls shared with other clients
+	Calls *CallLimiter
+	// MaxAttempts is how many times a call is tried before giving up
+	// (default defaultMaxAttempts)
+	MaxAttempts int
+	// Retries, if set, counts how calls fared across attempts
+	Retries *RetryStats
+	// MaxResponseBytes caps how much of a response body is read
+	// (default defaultMaxResponseBytes)
+	MaxResponseBytes int64
+	// Mock answers every lookup with a fake name instead of calling the
+	// API; see mockLookupResponse
+	Mock bool
+	// NameJSONPath is the dotted path of the name in the response body,
+	// for resellers that wrap Digitap (default defaultNameJSONPath)
+	NameJSONPath string
+	// ExtraFields are static fields added to every request body, for
+	// product tiers that need more than the mobile, name and reference
+	ExtraFields map[string]interface{}
+	// RetryMalformed retries responses that can't be parsed. They are
+	// usually deterministic, so by default they fail straight away.
+	RetryMalformed bool
+}
+
+// requestFields are the request body fields the client always sets itself
+var requestFields = []string{"client_ref_num", "mobile", "name"}
+
+// parseExtraFields reads a JSON object of static request fields, such as
+// {"consent": "Y", "purpose": "KYC"}. Fields the client sets itself are
+// rejected rather than silently overridden.
+func parseExtraFields(data string) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, fmt.Errorf("must be a JSON object: %v", err)
+	}
+	for _, key := range requestFields {
+		if _, ok := fields[key]; ok {
+			return nil, fmt.Errorf("can't set %q, which the client always sends", key)
+		}
+	}
+	return fields, nil
+}
+
+// defaultMaxResponseBytes is the largest provider response read by default
+const defaultMaxResponseBytes = 1 << 20
+
+// defaultMaxAttempts is how many times a provider call is tried by default
+const defaultMaxAttempts = 3
+
+// A response that can't be parsed is logged up to badResponseLogBytes and
+// kept in the response log up to badResponseStoreBytes
+const (
+	badResponseLogBytes   = 512
+	badResponseStoreBytes = 16 << 10
+)
+
+// truncateBody returns up to n bytes of body as valid UTF-8
+func truncateBody(body []byte, n int) string {
+	if len(body) > n {
+		body = body[:n]
+	}
+	return strings.ToValidUTF8(string(body), "")
 }
 
 // NewDigitapClient creates a new client instance
@@ -47,21 +131,89 @@ func NewDigitapClient(baseURL, authToken string) *DigitapClient {
 	}
 }
 
+// Lookup implements NameLookupProvider using the Digitap mobile name lookup API
+func (c *DigitapClient) Lookup(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+	clientRefNum := req.ClientRef
+	if clientRefNum == "" {
+		clientRefNum = deriveClientRef(req.Mobile, time.Now())
+	}
+
+	name := c.Name
+	if name == "" {
+		name = "digitap"
+	}
+
+	if err := c.Breaker.Allow(); err != nil {
+		return nil, &ProviderError{Provider: name, Err: err}
+	}
+
+	response, err := c.LookupMobileName(ctx, clientRefNum, req.Mobile, req.Name)
+	switch {
+	case err == nil:
+		c.Breaker.Success()
+	case ctx.Err() != nil:
+		// The caller gave up; that says nothing about the API
+		c.Breaker.Release()
+	default:
+		c.Breaker.Failure()
+	}
+	if err != nil {
+		perr, ok := err.(*ProviderError)
+		if !ok {
+			perr = &ProviderError{Err: err}
+		}
+		perr.Provider = name
+		return nil, perr
+	}
+	candidates := candidateNames(response.Result.MobileLinkedName, response.Result.CandidateNames)
+	return &LookupResult{
+		Name:           response.Result.MobileLinkedName,
+		Candidates:     candidates,
+		NameMatch:      response.Result.NameMatch,
+		NameMatchScore: response.Result.NameMatchScore,
+		Fields:         response.ResultFields,
+		Provider:       name,
+		StatusCode:     response.StatusCode,
+	}, nil
+}
+
 // LookupMobileName performs the mobile name lookup with retry logic
-func (c *DigitapClient) LookupMobileName(clientRefNum, mobile, name string) (*MobileNameLookupResponse, error) {
+func (c *DigitapClient) LookupMobileName(ctx context.Context, clientRefNum, mobile, name string) (*MobileNameLookupResponse, error) {
+	if c.Mock {
+		return mockLookupResponse(mobile, name), nil
+	}
+
 	url := c.BaseURL + "/validation/misc/v1/mobile-name-lookup"
 
-	payload := fmt.Sprintf(`{
-		"client_ref_num": "%s",
-		"mobile": "%s",
-		"name": "%s"
-	}`, clientRefNum, mobile, name)
+	fields := make(map[string]interface{}, len(c.ExtraFields)+len(requestFields))
+	for key, value := range c.ExtraFields {
+		fields[key] = value
+	}
+	fields["client_ref_num"] = clientRefNum
+	fields["mobile"] = mobile
+	fields["name"] = name
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
 
-	maxRetries := 3
+	maxAttempts := c.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
 	var lastErr error
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		req, err := http.NewRequest("POST", url, strings.NewReader(payload))
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		// Stop retrying once the overall request budget is spent
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if attempt > 0 {
+			c.Retries.Retry()
+		}
+
+		req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %v", err)
 		}
@@ -70,121 +222,159 @@ func (c *DigitapClient) LookupMobileName(clientRefNum, mobile, name string) (*Mo
 		req.Header.Add("Content-Type", "application/json")
 
 		// Set timeout for the request
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		attemptCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
-		req = req.WithContext(ctx)
+		req = req.WithContext(attemptCtx)
 
+		// Hold a slot under MAX_CONCURRENT_PROVIDER_CALLS only while the
+		// call is on the wire, not during the backoff between attempts
+		if err := c.Calls.Acquire(ctx); err != nil {
+			return nil, err
+		}
 		resp, err := c.HTTPClient.Do(req)
 		if err != nil {
+			c.Calls.Release()
 			lastErr = err
 			logger.WithError(err).WithField("attempt", attempt+1).Warn("Request failed, retrying...")
-			time.Sleep(time.Duration(attempt+1) * time.Second) // Exponential backoff
+			if err := sleepContext(ctx, time.Duration(attempt+1)*time.Second); err != nil { // Exponential backoff
+				return nil, err
+			}
 			continue
 		}
 		defer resp.Body.Close()
 
-		body, err := ioutil.ReadAll(resp.Body)
+		// Never buffer an unbounded body from a misbehaving upstream; read
+		// one byte past the cap to tell a full-size body from an oversized one
+		maxBytes := c.MaxResponseBytes
+		if maxBytes <= 0 {
+			maxBytes = defaultMaxResponseBytes
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+		c.Calls.Release()
+		if err == nil && int64(len(body)) > maxBytes {
+			return nil, &ProviderError{
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("response body exceeds %d bytes", maxBytes),
+			}
+		}
 		if err != nil {
 			lastErr = err
 			logger.WithError(err).WithField("attempt", attempt+1).Warn("Failed to read response, retrying...")
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+			if err := sleepContext(ctx, time.Duration(attempt+1)*time.Second); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// An error status is a failure even when its body parses. Rate
+		// limits and server errors are worth another attempt; any other
+		// refusal would only be refused again.
+		if errorStatus(resp.StatusCode) {
+			logger.WithFields(logrus.Fields{
+				"attempt":     attempt + 1,
+				"status_code": resp.StatusCode,
+			}).Warn("Provider returned an error status")
+
+			perr := &ProviderError{
+				StatusCode: resp.StatusCode,
+				Body:       truncateBody(body, badResponseStoreBytes),
+				Err:        fmt.Errorf("%w: %d", ErrProviderStatus, resp.StatusCode),
+			}
+			if !retryableStatus(resp.StatusCode) {
+				return nil, perr
+			}
+			if attempt == maxAttempts-1 {
+				c.Retries.Exhausted()
+				return nil, perr
+			}
+			lastErr = perr
+			if err := sleepContext(ctx, time.Duration(attempt+1)*time.Second); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
 		var response MobileNameLookupResponse
 		if err := json.Unmarshal(body, &response); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %v", err)
+			logger.WithError(err).WithFields(logrus.Fields{
+				"attempt":     attempt + 1,
+				"status_code": resp.StatusCode,
+				"body":        truncateBody(body, badResponseLogBytes),
+			}).Warn("Provider returned a malformed response")
+
+			perr := &ProviderError{
+				StatusCode: resp.StatusCode,
+				Body:       truncateBody(body, badResponseStoreBytes),
+				Err:        fmt.Errorf("%w: %v", ErrBadProviderResponse, err),
+			}
+			if !c.RetryMalformed || attempt == maxAttempts-1 {
+				return nil, perr
+			}
+			lastErr = perr
+			if err := sleepContext(ctx, time.Duration(attempt+1)*time.Second); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		response.StatusCode = resp.StatusCode
+		if c.NameJSONPath != "" && c.NameJSONPath != defaultNameJSONPath {
+			response.Result.MobileLinkedName = jsonPathString(body, c.NameJSONPath)
 		}
 
+		var raw struct {
+			Result map[string]interface{} `json:"result"`
+		}
+		if err := json.Unmarshal(body, &raw); err == nil {
+			response.ResultFields = raw.Result
+		}
+
+		c.Retries.Success(attempt + 1)
 		return &response, nil
 	}
 
+	c.Retries.Exhausted()
 	return nil, fmt.Errorf("all retry attempts failed: %v", lastErr)
 }
 
+// retryableStatus reports whether a provider error status may clear up on
+// another attempt
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // HTML template for the mobile interface
 const htmlTemplate = `
 <!DOCTYPE html>
 <html>
 <head>
-    <title>Mobile Name Lookup</title>
+    <title>{{.Brand.Title}}</title>
     <meta name="viewport" content="width=device-width, initial-scale=1">
+    <link rel="icon" href="{{.BasePath}}/static/favicon.svg" type="image/svg+xml">
+    <link rel="stylesheet" href="{{.BasePath}}/static/style.css?v={{.AssetVersion}}">
     <style>
-        body {
-            font-family: Arial, sans-serif;
-            margin: 0;
-            padding: 20px;
-            background-color: #f5f5f5;
-        }
-        .container {
-            max-width: 600px;
-            margin: 0 auto;
-            background: white;
-            padding: 20px;
-            border-radius: 10px;
-            box-shadow: 0 2px 5px rgba(0,0,0,0.1);
-        }
-        .form-group {
-            margin-bottom: 15px;
-        }
-        label {
-            display: block;
-            margin-bottom: 5px;
-            font-weight: bold;
-        }
-        input {
-            width: 100%;
-            padding: 8px;
-            border: 1px solid #ddd;
-            border-radius: 4px;
-            box-sizing: border-box;
-        }
-        button {
-            background-color: #4CAF50;
-            color: white;
-            padding: 10px 15px;
-            border: none;
-            border-radius: 4px;
-            cursor: pointer;
-            width: 100%;
-        }
-        button:hover {
-            background-color: #45a049;
-        }
-        .result {
-            margin-top: 20px;
-            padding: 15px;
-            border-radius: 4px;
-            background-color: #f8f9fa;
-            font-size: 18px;
-            text-align: center;
-        }
-        .error {
-            color: #dc3545;
-            margin-top: 10px;
-            text-align: center;
-        }
-        .db-record {
-            margin-top: 20px;
-            padding: 15px;
-            border-radius: 4px;
-            background-color: #e9ecef;
-            font-size: 16px;
-        }
-        .db-record strong {
-            color: #495057;
-        }
-        .timestamp {
-            font-size: 14px;
-            color: #6c757d;
-            margin-top: 5px;
+        :root {
+            --brand-color: {{.Brand.Color}};
+            --brand-hover-color: {{.Brand.HoverColor}};
         }
     </style>
 </head>
 <body>
     <div class="container">
-        <h1>Mobile Name Lookup</h1>
-        <form method="POST" action="/lookup_post">
+        {{with .Brand.LogoURL}}<img class="logo" src="{{.}}" alt="">{{end}}
+        <h1>{{.Brand.Title}}</h1>
+        <form method="POST" action="{{.BasePath}}/lookup_post">
             <div class="form-group">
                 <label for="mobile">Mobile Number:</label>
                 <input type="tel" id="mobile" name="mobile" required 
@@ -194,8 +384,39 @@ const htmlTemplate = `
                     Supports formats: 8318090009, +91 83180 90009, +91-83180-90009
                 </small>
             </div>
+            <div class="form-group">
+                <label for="name">Name to verify (optional):</label>
+                <input type="text" id="name" name="name" placeholder="e.g., John Doe" value="{{.VerifyName}}">
+            </div>
+            <div class="form-group checkbox">
+                <input type="checkbox" id="refresh" name="refresh" value="true">
+                <label for="refresh">Force refresh (skip the cache and query the provider)</label>
+            </div>
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
             <button type="submit">Lookup</button>
         </form>
+        <form method="POST" action="{{.BasePath}}/lookup_post">
+            <div class="form-group">
+                <label for="mobiles">Multiple Numbers (one per line):</label>
+                <textarea id="mobiles" name="mobiles" rows="5" required
+                          placeholder="8318090009&#10;+91 83180 90009"></textarea>
+            </div>
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <button type="submit">Lookup All</button>
+        </form>
+        {{if .Batch}}
+        <table class="batch-results">
+            <tr><th>Number</th><th>Name</th><th>Source</th><th></th></tr>
+            {{range .Batch}}
+            <tr>
+                <td>{{if .Mobile}}{{.Mobile}}{{else}}{{.Input}}{{end}}</td>
+                <td>{{.Name}}</td>
+                <td>{{.Source}}</td>
+                <td class="error">{{.Error}}</td>
+            </tr>
+            {{end}}
+        </table>
+        {{end}}
         {{if .Record}}
         <div class="db-record">
             <strong>Name:</strong> {{.Record.Name}}<br>
@@ -206,6 +427,18 @@ const htmlTemplate = `
         <div class="result">
             {{if .Result.Result.MobileLinkedName}}
             <strong>Name:</strong> {{.Result.Result.MobileLinkedName}}
+            {{with .Result.Result.NameMatch}}<br><strong>Name match:</strong> {{.}}{{end}}
+            {{with .Result.Result.NameMatchScore}}<br><strong>Match score:</strong> {{.}}{{end}}
+            {{with .Match}}<br><strong>Verified match:</strong> {{.}}{{end}}
+            {{with .MatchScore}}<br><strong>Verified score:</strong> {{printf "%.0f" .}}{{end}}
+            {{with .OtherNames}}
+            <details class="other-names">
+                <summary>Other possible names</summary>
+                <ul>{{range .}}<li>{{.}}</li>{{end}}</ul>
+            </details>
+            {{end}}
+            {{else if .Result.Message}}
+            {{.Result.Message}}
             {{else}}
             No name found for this number
             {{end}}
@@ -223,13 +456,45 @@ const htmlTemplate = `
 
 // PageData represents the data passed to the template
 type PageData struct {
-	Result *MobileNameLookupResponse
-	Error  string
-	Record *db.MobileRecord
+	Result    *MobileNameLookupResponse
+	Error     string
+	Record    *db.MobileRecord
+	Batch     []batchRow
+	CSRFToken string
+	BasePath  string
+	Brand     Brand
+
+	// Match and MatchScore are the strict verification verdict, if any
+	Match      *bool
+	MatchScore *float64
+	// OtherNames are the provider's candidate names besides the primary
+	OtherNames []string
+	// VerifyName refills the name field with the name actually queried
+	VerifyName string
+	// AssetVersion busts browser caches of the stylesheet on deploys
+	AssetVersion string
 }
 
 // Logger instance
-var logger = logrus.New()
+var baseLogger = logrus.New()
+
+// logger is the entry every log line is written through. configureLogger
+// attaches the service and environment fields so lines can be told apart in
+// a shared log aggregator.
+var logger = logrus.NewEntry(baseLogger)
+
+// LimitResult describes the outcome of a rate limit check
+type LimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key may proceed
+type Limiter interface {
+	Allow(key string) LimitResult
+}
 
 // RateLimiter represents a rate limiter for an IP
 type RateLimiter struct {
@@ -280,12 +545,54 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	return limiter.limiter
 }
 
-// Middleware for rate limiting
-func rateLimitMiddleware(next http.HandlerFunc, limiter *IPRateLimiter) http.HandlerFunc {
+// Allow implements Limiter using the in-memory per-IP token bucket
+func (i *IPRateLimiter) Allow(ip string) LimitResult {
+	limiter := i.GetLimiter(ip)
+	now := time.Now()
+
+	result := LimitResult{Allowed: true, Limit: i.burst}
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// Give the token back; the request is rejected rather than delayed
+		reservation.CancelAt(now)
+		result.Allowed = false
+		result.RetryAfter = delay
+	}
+	if tokens := limiter.TokensAt(now); tokens > 0 {
+		result.Remaining = int(tokens)
+	}
+	return result
+}
+
+// remoteIP returns the address of the client without its port
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Middleware for rate limiting. Every response carries X-RateLimit-Limit and
+// X-RateLimit-Remaining; rejected requests also get Retry-After in seconds.
+func rateLimitMiddleware(next http.HandlerFunc, limiter Limiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		if !limiter.GetLimiter(ip).Allow() {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		// Keyed by address alone, as a client's port changes with each
+		// connection
+		ip := remoteIP(r)
+		result := limiter.Allow(ip)
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			if isAPIPath(r) {
+				writeJSONError(w, http.StatusTooManyRequests, errCodeRateLimited, "Rate limit exceeded")
+			} else {
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			}
 			logger.WithFields(logrus.Fields{
 				"ip":     ip,
 				"status": "rate_limited",
@@ -296,43 +603,68 @@ func rateLimitMiddleware(next http.HandlerFunc, limiter *IPRateLimiter) http.Han
 	}
 }
 
-// cleanPhoneNumber removes all non-digit characters and handles country codes
-func cleanPhoneNumber(phone string) (string, error) {
-	// Remove all non-digit characters
-	re := regexp.MustCompile(`[^\d]`)
-	digits := re.ReplaceAllString(phone, "")
-
-	// Handle different formats
-	if len(digits) == 0 {
-		return "", fmt.Errorf("no digits found in phone number")
+// isPlausibleNumber reports whether a cleaned 10-digit number looks real.
+// Numbers made of one repeated digit (9999999999) or a simple ascending or
+// descending run (6789012345, 9876543210) are rejected as obvious fakes.
+func isPlausibleNumber(digits string) bool {
+	if len(digits) < 2 {
+		return true
 	}
 
-	// If it starts with country code (e.g., 91 for India), remove it
-	if len(digits) > 10 {
-		// Common country codes: 91 (India), 1 (US/Canada), 44 (UK), etc.
-		if strings.HasPrefix(digits, "91") && len(digits) == 12 {
-			digits = digits[2:] // Remove 91
-		} else if strings.HasPrefix(digits, "1") && len(digits) == 11 {
-			digits = digits[1:] // Remove 1
-		} else if strings.HasPrefix(digits, "44") && len(digits) == 12 {
-			digits = digits[2:] // Remove 44
-		} else if len(digits) > 10 {
-			// For other country codes, try to extract the last 10 digits
-			digits = digits[len(digits)-10:]
+	same, ascending, descending := true, true, true
+	for i := 1; i < len(digits); i++ {
+		prev, cur := int(digits[i-1]-'0'), int(digits[i]-'0')
+		if cur != prev {
+			same = false
+		}
+		if cur != (prev+1)%10 {
+			ascending = false
+		}
+		if cur != (prev+9)%10 {
+			descending = false
 		}
 	}
 
-	// Validate the final number
-	if len(digits) != 10 {
-		return "", fmt.Errorf("invalid phone number length: %d digits (expected 10)", len(digits))
-	}
+	return !same && !ascending && !descending
+}
 
-	// Check if it's a valid Indian mobile number (starts with 6, 7, 8, 9)
-	if !regexp.MustCompile(`^[6-9]\d{9}$`).MatchString(digits) {
-		return "", fmt.Errorf("invalid mobile number format")
+// newProviderTransport builds the transport for provider calls. They go
+// through HTTPS_PROXY/HTTP_PROXY unless DIGITAP_PROXY_URL overrides them.
+func newProviderTransport(cfg *Config) *http.Transport {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProviderProxy != nil {
+		proxy = http.ProxyURL(cfg.ProviderProxy)
+		logger.WithField("proxy", cfg.ProviderProxy.Redacted()).Info("Provider calls use DIGITAP_PROXY_URL")
 	}
-
-	return digits, nil
+	tuning := cfg.ProviderTransport
+	transport := &http.Transport{
+		Proxy:               proxy,
+		MaxIdleConns:        tuning.MaxIdleConns,
+		MaxIdleConnsPerHost: tuning.MaxIdleConnsPerHost,
+		IdleConnTimeout:     tuning.IdleConnTimeout,
+		DisableKeepAlives:   tuning.DisableKeepAlives,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	// Optionally pin the provider's certificate. The transport keeps no
+	// TLS session cache, so every connection goes through the check.
+	if len(cfg.PinnedCerts) > 0 {
+		transport.TLSClientConfig = &tls.Config{VerifyPeerCertificate: pinnedCertVerifier(cfg.PinnedCerts)}
+		logger.WithField("pins", len(cfg.PinnedCerts)).Info("Provider certificate pinning enabled")
+	}
+	// A non-nil, empty TLSNextProto turns HTTP/2 off. Note that Go only
+	// negotiates HTTP/2 by default while TLSClientConfig is unset, so
+	// pinning already implies HTTP/1.1.
+	if tuning.ForceHTTP1 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	logger.WithFields(logrus.Fields{
+		"max_idle_conns":          transport.MaxIdleConns,
+		"max_idle_conns_per_host": transport.MaxIdleConnsPerHost,
+		"idle_conn_timeout":       transport.IdleConnTimeout.String(),
+		"keep_alives":             !transport.DisableKeepAlives,
+		"http2":                   !tuning.ForceHTTP1 && transport.TLSClientConfig == nil,
+	}).Info("Provider transport configured")
+	return transport
 }
 
 func main() {
@@ -344,63 +676,276 @@ func main() {
 	}
 
 	// Configure logging
-	logger.SetFormatter(&logrus.JSONFormatter{})
-	logger.SetOutput(os.Stdout)
+	baseLogger.SetOutput(os.Stdout)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid configuration")
+	}
+
+	if err := configureLogger(cfg.LogFormat, cfg.LogLevel, cfg.ServiceName, cfg.Environment); err != nil {
+		logger.WithError(err).Fatal("Invalid logging configuration")
+	}
+
+	// Full mobile numbers only appear in logs when LOG_PII is enabled
+	logPII = cfg.LogPII
+	piiHashKey = []byte(cfg.PIIHashKey)
+	defaultCountryCode = cfg.DefaultCountryCode
 
 	// Initialize database
-	database, err := db.NewDB()
+	logger.WithFields(logrus.Fields{
+		"max_open_conns":    cfg.DBPool.MaxOpenConns,
+		"max_idle_conns":    cfg.DBPool.MaxIdleConns,
+		"conn_max_lifetime": cfg.DBPool.ConnMaxLifetime.String(),
+	}).Info("Database pool configured")
+
+	cfg.DBRetry.OnRetry = func(attempt int, wait time.Duration, err error) {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"attempt": attempt,
+			"wait":    wait.String(),
+		}).Warn("Database not reachable yet, retrying")
+	}
+
+	database, err := db.NewDB(cfg.DatabaseURL, cfg.DBPool, cfg.DBRetry)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to connect to database")
 	}
 	defer database.Close()
 
+	// Cache and list reads go to the replica when one is configured
+	if cfg.DatabaseReadURL != "" {
+		if err := database.OpenReplica(cfg.DatabaseReadURL, cfg.DBPool, cfg.DBRetry); err != nil {
+			logger.WithError(err).Fatal("Failed to connect to read replica")
+		}
+		logger.Info("Reading the cache from the read replica")
+	}
+
+	database.TablePrefix = cfg.DBTablePrefix
+
+	// Names longer than this are truncated before they're stored
+	database.MaxNameLength = cfg.MaxNameLength
+	database.OnNameSanitized = func(mobile, original, stored string) {
+		logger.WithFields(mobileLogFields(mobile)).WithFields(logrus.Fields{
+			"original_length": len(original),
+			"stored_length":   len(stored),
+		}).Warn("Name truncated or cleaned before saving")
+	}
+
 	// Test database connection
 	if err := database.TestConnection(); err != nil {
 		logger.WithError(err).Fatal("Failed to test database connection")
 	}
 	logger.Info("Successfully connected to database")
 
-	// Initialize database schema
-	if err := database.InitDB(); err != nil {
-		logger.WithError(err).Fatal("Failed to initialize database")
+	// Initialize database schema. A read-only instance never writes, so
+	// it expects the schema to be migrated already and can use a database
+	// user without write grants.
+	if cfg.ReadOnly {
+		logger.Info("READ_ONLY is enabled; skipping database migrations")
+	} else {
+		if err := database.InitDB(); err != nil {
+			logger.WithError(err).Fatal("Failed to initialize database")
+		}
+		logger.Info("Successfully initialized database schema")
 	}
-	logger.Info("Successfully initialized database schema")
 
-	// Get environment variables with defaults
-	baseURL := getEnvOrDefault("DIGITAP_BASE_URL", "https://svc.digitap.ai")
-	authToken := getEnvOrDefault("DIGITAP_AUTH_TOKEN", "")
-	if authToken == "" {
-		logger.Fatal("DIGITAP_AUTH_TOKEN environment variable is required")
+	// Handlers reach the database through the in-memory record cache
+	// when one is configured
+	var store Store = database
+	var records *recordCache
+	if cfg.RecordCacheSize > 0 {
+		records = newRecordCache(database, cfg.RecordCacheSize, cfg.RecordCacheTTL)
+		store = records
+		logger.WithFields(logrus.Fields{
+			"size": cfg.RecordCacheSize,
+			"ttl":  cfg.RecordCacheTTL.String(),
+		}).Info("In-memory record cache enabled")
 	}
 
 	// Create HTTP client with custom timeout
 	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 100,
-			IdleConnTimeout:     90 * time.Second,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
+		Timeout:   30 * time.Second,
+		Transport: newProviderTransport(cfg),
+	}
+
+	// Create rate limiter (default 5 requests per minute per IP)
+	limitRate := rate.Every(time.Minute / time.Duration(cfg.RateLimitPerMinute))
+	logger.WithFields(logrus.Fields{
+		"requests_per_minute": cfg.RateLimitPerMinute,
+		"burst":               cfg.RateLimitBurst,
+	}).Info("Rate limiter configured")
+	var limiter Limiter = NewIPRateLimiter(limitRate, cfg.RateLimitBurst)
+	if cfg.RateLimiterBackend == "redis" {
+		redisClient, err := newRedisClient(cfg.RedisURL)
+		if err != nil {
+			logger.WithError(err).Fatal("Invalid REDIS_URL")
+		}
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			logger.WithError(err).Warn("Redis unavailable, using in-memory rate limiter until it recovers")
+		}
+		limiter = NewRedisRateLimiter(redisClient, limitRate, cfg.RateLimitBurst, limiter)
+		logger.Info("Using Redis-backed rate limiter")
 	}
 
-	// Create rate limiter (5 requests per minute per IP)
-	limiter := NewIPRateLimiter(rate.Every(12*time.Second), 5)
+	// Create the lookup provider chain from the configured provider list,
+	// with a circuit breaker per provider unless disabled
+	breakers := make(map[string]*CircuitBreaker)
+	if cfg.BreakerThreshold > 0 {
+		for _, p := range cfg.Providers {
+			breakers[p.Name] = NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown)
+		}
+	}
+	// MAX_CONCURRENT_PROVIDER_CALLS caps calls in flight across every
+	// provider and tenant
+	var calls *CallLimiter
+	if cfg.MaxProviderCalls > 0 {
+		calls = NewCallLimiter(cfg.MaxProviderCalls)
+	}
+	retries := NewRetryStats()
+	provider := newProvider(cfg.Providers, httpClient, breakers, calls, retries)
+	for _, p := range cfg.Providers {
+		if p.Mock {
+			logger.WithField("provider", p.Name).Warn("Provider mock mode is active; lookups return fake names and never call the API")
+		}
+	}
 
-	// Create client with custom HTTP client
-	client := &DigitapClient{
-		BaseURL:    baseURL,
-		AuthToken:  authToken,
-		HTTPClient: httpClient,
+	// Optionally look up a known number once so bad credentials fail the
+	// deploy instead of the first user request. Dry-run mode never calls
+	// the paid API, so it skips the canary.
+	if cfg.CanaryNumber != "" {
+		mobile, err := cleanPhoneNumber(cfg.CanaryNumber)
+		if err != nil {
+			logger.WithError(err).Fatal("Invalid STARTUP_CANARY_NUMBER")
+		}
+		if cfg.DryRun || cfg.ReadOnly {
+			logger.Info("DRY_RUN or READ_ONLY is enabled; skipping the startup canary")
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.RequestTimeout)
+			err := runStartupCanary(ctx, provider, mobile)
+			cancel()
+			switch {
+			case errors.Is(err, errCanaryAuth) && cfg.CanaryFailFast:
+				logger.WithError(err).Fatal("Startup canary failed")
+			case err != nil:
+				logger.WithError(err).Warn("Startup canary failed; continuing")
+			}
+		}
+	}
+
+	// API keys protecting the JSON API; admin keys protect support and
+	// maintenance endpoints
+	apiKeys, adminKeys := cfg.APIKeys, cfg.AdminKeys
+	if len(apiKeys) == 0 {
+		logger.Warn("API_KEYS is not set; all /api/ requests will be rejected")
+	}
+
+	// Dry-run mode never calls the paid API; it can also be enabled per request with ?dryrun=true
+	if cfg.DryRun {
+		logger.Warn("DRY_RUN is enabled; cache misses will not call the lookup API")
 	}
 
+	if cfg.AlwaysFresh {
+		logger.Warn("ALWAYS_FRESH is enabled; every lookup calls the provider")
+	}
+
+	// Optional webhook notified whenever a new name is resolved from the API
+	var notifier *WebhookNotifier
+	if cfg.WebhookURL != "" {
+		if cfg.WebhookSecret == "" {
+			logger.Warn("WEBHOOK_SECRET is not set; webhook signatures use an empty key")
+		}
+		notifier = NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret)
+	}
+
+	// In-memory counters of how lookups were served
+	stats := NewLookupStats()
+	stats.dbPool = database.Stats
+	stats.lockPool = database.LockPoolStats
+	stats.breakers = breakers
+	stats.retries = retries
+	stats.recordCache = records
+
+	// Optional prefix all routes are mounted under, e.g. /name-lookup
+	routePrefix := cfg.RoutePrefix
+
+	service := &lookupService{
+		database:         store,
+		provider:         provider,
+		notifier:         notifier,
+		stats:            stats,
+		rejectSuspicious: cfg.RejectSuspicious,
+		dryRunMode:       cfg.DryRun,
+		nameMode:         cfg.NameMode,
+		nameBlocklist:    newNameBlocklist(cfg.NameBlocklist, cfg.NameBlocklistPattern),
+		minConfidence:    cfg.MinConfidence,
+		batchConcurrency: cfg.BatchConcurrency,
+		alwaysFresh:      cfg.AlwaysFresh,
+		strictVerify:     cfg.StrictVerify,
+		matchThreshold:   cfg.MatchThreshold,
+		logSampleRate:    cfg.APILogSampleRate,
+		degradeOnDBError: cfg.DegradeOnDBError,
+		unknownName:      cfg.UnknownName,
+		storeRawMobile:   cfg.StoreRawMobile,
+		recent:           newRecentLookups(cfg.DedupeWindow),
+		readOnly:         cfg.ReadOnly,
+	}
+
+	// Optionally take response log writes off the request path
+	if cfg.ResponseLogAsync {
+		service.responseLogs = newResponseLogWriter(store, cfg.ResponseLogBuffer, cfg.ResponseLogBatchSize, cfg.ResponseLogFlushInterval)
+		stats.responseLogs = service.responseLogs
+		logger.WithFields(logrus.Fields{
+			"batch_size":     cfg.ResponseLogBatchSize,
+			"flush_interval": cfg.ResponseLogFlushInterval.String(),
+			"buffer":         cfg.ResponseLogBuffer,
+		}).Info("Saving API response logs in the background")
+	}
+
+	// Requests with an X-Tenant header use that tenant's sub-account
+	// instead of the providers above
+	if len(cfg.Tenants) > 0 {
+		service.tenants = NewTenantResolver(cfg.Tenants, httpClient, calls, retries, cfg.Providers[0].MaxResponseBytes, cfg.BreakerThreshold, cfg.BreakerCooldown)
+		logger.WithField("tenants", len(cfg.Tenants)).Info("Per-tenant providers configured")
+	}
+
+	// Admins can re-query numbers that never resolved; progress shows up
+	// in the stats
+	backfill := newBackfillJob(service, store, cfg.BackfillConcurrency, cfg.BackfillRatePerMinute, cfg.RequestTimeout)
+	stats.backfill = backfill
+
 	// Parse template
 	tmpl := template.Must(template.New("mobile").Parse(htmlTemplate))
 
+	// render executes the page template with a CSRF token so the rendered
+	// form can always be submitted again
+	var render renderFunc = func(w http.ResponseWriter, r *http.Request, data PageData) {
+		token, err := ensureCSRFToken(w, r)
+		if err != nil {
+			logger.WithError(err).Error("Failed to issue CSRF token")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		data.CSRFToken = token
+		data.BasePath = routePrefix
+		data.Brand = cfg.Brand
+		data.AssetVersion = staticVersion
+
+		// Only the empty form is served on GET; results and errors come
+		// from POSTs and must never be cached
+		if r.Method == http.MethodGet {
+			if setFormPageCacheHeaders(w, r, formPageETag(data)) {
+				return
+			}
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		renderTemplate(w, r, tmpl, data)
+	}
+
 	mux := http.NewServeMux()
 
 	// Handle root path - GET request to show the form
-	mux.HandleFunc("/", rateLimitMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	homeHandler := func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
 			http.NotFound(w, r)
 			return
@@ -410,185 +955,286 @@ func main() {
 			return
 		}
 		// Show empty form
-		tmpl.Execute(w, PageData{})
-	}, limiter))
+		render(w, r, PageData{})
+	}
 
 	// Handle form submission - POST request
-	mux.HandleFunc("/lookup_post", rateLimitMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			// Redirect GET requests to home page
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			return
-		case http.MethodPost:
-			// Handle POST request
-			var mobile string
-
-			// Check if it's a JSON request
-			if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
-				var requestBody struct {
-					Mobile string `json:"mobile"`
-				}
-				if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-					logger.WithError(err).Error("Failed to decode JSON body")
-					if isAPIRequest(r) {
-						respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
-							"error": "Invalid JSON body",
-						})
-					} else {
-						http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-					}
-					return
-				}
-				mobile = requestBody.Mobile
-			} else {
-				// Handle form data
-				if err := r.ParseForm(); err != nil {
-					logger.WithError(err).Error("Failed to parse form")
-					http.Error(w, "Invalid form data", http.StatusBadRequest)
-					return
-				}
-				mobile = r.FormValue("mobile")
-			}
+	lookupHandler := newLookupHandler(service, render, routePrefix, adminKeys)
 
-			if mobile == "" {
-				if isAPIRequest(r) {
-					respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
-						"error": "Mobile number is required",
-					})
-				} else {
-					tmpl.Execute(w, PageData{Error: "Mobile number is required"})
-				}
-				return
-			}
+	// The human-facing routes stay open unless REQUIRE_API_KEY_FOR_UI is set
+	protectUI := func(next http.HandlerFunc) http.HandlerFunc {
+		if cfg.RequireKeyForUI {
+			return apiKeyMiddleware(next, apiKeys)
+		}
+		return next
+	}
 
-			// Clean and validate mobile number
-			mobile, err := cleanPhoneNumber(mobile)
-			if err != nil {
-				if isAPIRequest(r) {
-					respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
-						"error": fmt.Sprintf("Invalid mobile number: %v", err),
-					})
-				} else {
-					tmpl.Execute(w, PageData{Error: fmt.Sprintf("Invalid mobile number: %v", err)})
-				}
-				return
-			}
+	// Routes that can reach the paid API also count against the daily
+	// per-IP quota when DAILY_QUOTA_PER_IP is set. In read-only mode they
+	// can't, and the quota's counters would be database writes.
+	withQuota := func(next http.HandlerFunc) http.HandlerFunc {
+		if cfg.DailyQuotaPerIP > 0 && !cfg.ReadOnly {
+			return dailyQuotaMiddleware(next, NewDailyQuota(store, cfg.DailyQuotaPerIP))
+		}
+		return next
+	}
 
-			// Log request
-			logger.WithFields(logrus.Fields{
-				"raw_mobile":   mobile,
-				"clean_mobile": mobile,
-				"ip":           r.RemoteAddr,
-				"method":       r.Method,
-			}).Info("Lookup request received")
-
-			// First, check if we have the record in our database
-			record, err := database.GetMobileRecord(mobile)
-			if err != nil {
-				logger.WithError(err).Error("Failed to query database")
-				if isAPIRequest(r) {
-					respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-						"error": "Database error occurred",
-					})
-				} else {
-					tmpl.Execute(w, PageData{Error: "Database error occurred"})
-				}
-				return
-			}
+	// MAINTENANCE_MODE turns away everything that can call the provider,
+	// while the form, docs and admin reads keep working
+	duringMaintenance := func(next http.HandlerFunc) http.HandlerFunc {
+		if cfg.MaintenanceMode {
+			return maintenanceMiddleware(next, cfg.MaintenanceMessage, render)
+		}
+		return next
+	}
+	if cfg.MaintenanceMode {
+		logger.Warn("MAINTENANCE_MODE is enabled; lookups are answered with 503")
+	}
 
-			if record != nil {
-				// We found the record in our database
-				logger.WithFields(logrus.Fields{
-					"mobile": mobile,
-					"name":   record.Name,
-				}).Info("Found record in database")
-
-				if isAPIRequest(r) {
-					respondWithJSON(w, http.StatusOK, map[string]interface{}{
-						"status": "success",
-						"result": map[string]interface{}{
-							"mobile_linked_name": record.Name,
-							"mobile":             record.Mobile,
-						},
-						"source": "database",
-					})
-				} else {
-					tmpl.Execute(w, PageData{Record: record})
-				}
-				return
-			}
+	// READ_ONLY keeps admin reads but refuses the routes that write
+	readOnly := func(next http.HandlerFunc) http.HandlerFunc {
+		if cfg.ReadOnly {
+			return readOnlyMiddleware(next)
+		}
+		return next
+	}
+	if cfg.ReadOnly {
+		logger.Warn("READ_ONLY is enabled; only cached names are served")
+	}
 
-			// If not in database, query the API
-			clientRefNum := fmt.Sprintf("REF_%d", time.Now().Unix())
-			name := ""
+	mux.HandleFunc("/", rateLimitMiddleware(protectUI(homeHandler), limiter))
+	mux.HandleFunc("/lookup_post", rateLimitMiddleware(protectUI(duringMaintenance(withQuota(lookupHandler))), limiter))
+
+	// Static assets, API docs and build info are public and not rate limited
+	mux.Handle("/static/", staticHandler())
+	mux.HandleFunc("/openapi.yaml", openAPIHandler)
+	mux.HandleFunc("/docs", docsHandler)
+	mux.HandleFunc("/version", versionHandler)
+
+	// JSON API routes always require an API key
+	mux.HandleFunc("/api/v1/lookup", rateLimitMiddleware(apiKeyMiddleware(duringMaintenance(withQuota(lookupHandler)), apiKeys), limiter))
+	mux.HandleFunc("/api/v1/batch", rateLimitMiddleware(apiKeyMiddleware(duringMaintenance(withQuota(batchLookupHandler(service))), apiKeys), limiter))
+	mux.HandleFunc("/api/v1/stats", rateLimitMiddleware(apiKeyMiddleware(statsHandler(stats), apiKeys), limiter))
+
+	// Admin routes require a key from ADMIN_API_KEYS
+	mux.HandleFunc("/api/v1/overrides", rateLimitMiddleware(apiKeyMiddleware(readOnly(overridesHandler(store)), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/reverse", rateLimitMiddleware(apiKeyMiddleware(reverseLookupHandler(store), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/search/suffix", rateLimitMiddleware(apiKeyMiddleware(suffixSearchHandler(store), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/cache/purge", rateLimitMiddleware(apiKeyMiddleware(readOnly(cachePurgeHandler(store)), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/backfill", rateLimitMiddleware(apiKeyMiddleware(readOnly(duringMaintenance(backfillHandler(backfill))), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/import", rateLimitMiddleware(apiKeyMiddleware(readOnly(importHandler(store, cfg.StoreRawMobile)), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/records/", rateLimitMiddleware(apiKeyMiddleware(readOnly(duringMaintenance(reverifyHandler(service))), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/recent", rateLimitMiddleware(apiKeyMiddleware(recentLookupsHandler(store), adminKeys), limiter))
+	mux.HandleFunc("/api/v1/audit", rateLimitMiddleware(apiKeyMiddleware(adminAuditHandler(store), adminKeys), limiter))
+
+	// Admin pages for browsers use basic auth with an admin key as the
+	// password
+	mux.HandleFunc("/admin/records", rateLimitMiddleware(basicAuthMiddleware(adminRecordsHandler(store, routePrefix, cfg.Brand), adminKeys), limiter))
+
+	// Browser frontends on other origins may call the JSON API when their
+	// origin is in CORS_ALLOWED_ORIGINS
+	var handler http.Handler = corsMiddleware(mux, cfg.CORSAllowedOrigins)
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		logger.WithField("origins", cfg.CORSAllowedOrigins).Info("CORS enabled for the JSON API")
+	}
 
-			response, err := client.LookupMobileName(clientRefNum, mobile, name)
+	// Mount the routes under the prefix; handlers see prefix-free paths
+	if routePrefix != "" {
+		root := http.NewServeMux()
+		root.Handle(routePrefix+"/", http.StripPrefix(routePrefix, handler))
+		root.HandleFunc(routePrefix, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, absoluteURL(r, routePrefix+"/"), http.StatusMovedPermanently)
+		})
+		handler = root
+	}
+
+	if service.tenants != nil {
+		handler = tenantMiddleware(handler, service.tenants)
+	}
+
+	// Behind a TLS-terminating proxy, redirects must keep the scheme the
+	// client used rather than the plain HTTP the proxy forwards
+	if cfg.TrustProxy {
+		handler = forwardedProtoMiddleware(handler)
+	}
+
+	// The timeout is the total budget for a request, including every
+	// provider retry. Every request gets an access log line.
+	handler = requestIDMiddleware(accessLogMiddleware(timeoutMiddleware(gzipMiddleware(maxBodyMiddleware(handler, cfg.MaxBodyBytes)), cfg.RequestTimeout)), cfg.RequestIDHeader)
+
+	logger.WithFields(logrus.Fields{
+		"version": version,
+		"commit":  commit,
+		"port":    cfg.Port,
+		"prefix":  routePrefix,
+		"tls":     cfg.TLSCertFile != "",
+	}).Info("Server starting")
+
+	// On SIGINT or SIGTERM stop taking requests, let the ones in flight
+	// finish, then write out the queued response logs
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: handler}
+	stopped := make(chan struct{})
+	go func() {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		<-ctx.Done()
+		stop()
+		logger.Info("Shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.WithError(err).Warn("Requests still in flight at shutdown")
+		}
+		if service.responseLogs != nil {
+			if err := service.responseLogs.Close(ctx); err != nil {
+				logger.WithError(err).Warn("Queued API response logs were not all saved")
+			}
+		}
+		close(stopped)
+	}()
+
+	// Warm the record cache in the background; requests are served
+	// meanwhile and read the database until it's done
+	if records != nil && cfg.CacheWarmupRecords > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cacheWarmupTimeout)
+			defer cancel()
+			start := time.Now()
+			warmed, err := records.Warm(ctx, cfg.CacheWarmupRecords)
+			fields := logrus.Fields{"records": warmed, "duration_ms": time.Since(start).Milliseconds()}
 			if err != nil {
-				logger.WithError(err).WithFields(logrus.Fields{
-					"mobile":     mobile,
-					"client_ref": clientRefNum,
-				}).Error("Lookup failed")
-
-				if isAPIRequest(r) {
-					respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-						"error": "Service temporarily unavailable. Please try again.",
-					})
-				} else {
-					tmpl.Execute(w, PageData{Error: "Service temporarily unavailable. Please try again."})
-				}
+				logger.WithError(err).WithFields(fields).Warn("Record cache warmup failed")
 				return
 			}
+			logger.WithFields(fields).Info("Record cache warmed")
+		}()
+	}
 
-			// If we got a name from the API, save it to our database
-			if response.Result.MobileLinkedName != "" {
-				if err := database.SaveMobileRecord(mobile, response.Result.MobileLinkedName); err != nil {
-					logger.WithError(err).Error("Failed to save record to database")
-				}
-			}
+	// Serve HTTPS directly when both a certificate and key are configured
+	if cfg.TLSCertFile == "" {
+		err = server.ListenAndServe()
+	} else {
+		// Optionally redirect plain HTTP to the HTTPS port
+		if cfg.HTTPRedirectPort != "" {
+			go func() {
+				logger.WithField("port", cfg.HTTPRedirectPort).Info("HTTP to HTTPS redirect listener starting")
+				log.Fatal(http.ListenAndServe(":"+cfg.HTTPRedirectPort, httpsRedirectHandler(cfg.Port)))
+			}()
+		}
+		err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-stopped
+}
 
-			logger.WithFields(logrus.Fields{
-				"mobile":     mobile,
-				"status":     response.Status,
-				"client_ref": clientRefNum,
-			}).Info("Lookup successful")
-
-			if isAPIRequest(r) {
-				respondWithJSON(w, http.StatusOK, map[string]interface{}{
-					"status":  response.Status,
-					"message": response.Message,
-					"result": map[string]interface{}{
-						"mobile_linked_name": response.Result.MobileLinkedName,
-						"mobile":             mobile,
-					},
-					"source": "api",
-				})
-			} else {
-				tmpl.Execute(w, PageData{Result: response})
-			}
-			return
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+// shutdownTimeout bounds how long shutdown waits for requests in flight and
+// queued response logs
+const shutdownTimeout = 30 * time.Second
+
+// httpsRedirectHandler permanently redirects every request to the same
+// host and path on the HTTPS port
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
 		}
-	}, limiter))
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// configureLogger applies the log format (json or text) and level
+// (debug, info, warn or error), and attaches the service and, when set, the
+// environment to every line
+func configureLogger(format, level, service, environment string) error {
+	switch strings.ToLower(format) {
+	case "json":
+		baseLogger.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+		baseLogger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	default:
+		return fmt.Errorf("unknown LOG_FORMAT %q (expected json or text)", format)
+	}
+
+	switch strings.ToLower(level) {
+	case "debug":
+		baseLogger.SetLevel(logrus.DebugLevel)
+	case "info":
+		baseLogger.SetLevel(logrus.InfoLevel)
+	case "warn":
+		baseLogger.SetLevel(logrus.WarnLevel)
+	case "error":
+		baseLogger.SetLevel(logrus.ErrorLevel)
+	default:
+		return fmt.Errorf("unknown LOG_LEVEL %q (expected debug, info, warn or error)", level)
+	}
+
+	fields := logrus.Fields{"service": service}
+	if environment != "" {
+		fields["environment"] = environment
+	}
+	logger = logrus.NewEntry(baseLogger).WithFields(fields)
+
+	return nil
+}
 
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// renderTemplate executes tmpl into a buffer and only writes it out when
+// execution succeeded, so a failing template yields a clean 500 instead of a
+// truncated page
+func renderTemplate(w http.ResponseWriter, r *http.Request, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		logger.WithError(err).
+			WithField("request_id", requestIDFromContext(r.Context())).
+			Error("Failed to render template")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	// Enable CORS for all origins (for development and mobile app use)
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// Middleware enforcing a total deadline on each request through its context.
+// Provider calls, retries and database queries share the deadline, so neither
+// a slow upstream nor a slow database can hold a connection longer than the
+// budget.
+func timeoutMiddleware(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// maxBodyMiddleware caps request bodies at limit bytes. Reads past the limit
+// fail with *http.MaxBytesError, which handlers report as 413.
+func maxBodyMiddleware(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
 	})
+}
 
-	logger.WithField("port", port).Info("Server starting")
-	log.Fatal(http.ListenAndServe(":"+port, c.Handler(mux)))
+// bodyTooLarge reports whether err came from reading past the body limit
+func bodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// respondBodyTooLarge reports an oversized request body with 413
+func respondBodyTooLarge(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		respondWithAPIError(w, r, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, "Request body too large")
+	} else {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+	}
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -598,8 +1244,30 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt parses an integer environment variable, returning the default when
+// it is unset and an error when it is set but not a valid integer
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// isAPIPath checks if the request targets one of the /api/ routes
+func isAPIPath(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
 // isAPIRequest checks if the request is from an API client
 func isAPIRequest(r *http.Request) bool {
+	if isAPIPath(r) {
+		return true
+	}
 	// Check if Accept header contains application/json
 	if strings.Contains(r.Header.Get("Accept"), "application/json") {
 		return true
@@ -614,6 +1282,62 @@ func isAPIRequest(r *http.Request) bool {
 	return false
 }
 
+// Error codes returned in the JSON error envelope
+const (
+	errCodeInvalidRequest   = "invalid_request"
+	errCodeInvalidMobile    = "invalid_mobile"
+	errCodeUnauthorized     = "unauthorized"
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeNotFound         = "not_found"
+	errCodeRateLimited      = "rate_limited"
+	errCodeUpstream         = "upstream_error"
+	errCodeDatabase         = "database_error"
+	errCodeTimeout          = "timeout"
+	errCodeBodyTooLarge     = "body_too_large"
+	errCodeConflict         = "conflict"
+	errCodeQuotaExceeded    = "quota_exceeded"
+	errCodeUnknownTenant    = "unknown_tenant"
+	errCodeProviderBusy     = "provider_busy"
+	errCodeForbidden        = "forbidden"
+	errCodeBadResponse      = "bad_provider_response"
+	errCodeMaintenance      = "maintenance"
+	errCodeReadOnly         = "read_only"
+
+	// Why a number was rejected, in place of the generic invalid_mobile
+	errCodeEmptyMobile        = "empty_mobile"
+	errCodeMobileLength       = "invalid_mobile_length"
+	errCodeMobileFormat       = "invalid_mobile_format"
+	errCodeUnsupportedCountry = "unsupported_country"
+)
+
+// writeJSONError sends the standard API error envelope
+// {"error": {"code": "...", "message": "..."}}
+func writeJSONError(w http.ResponseWriter, statusCode int, code, message string) {
+	respondWithJSON(w, statusCode, map[string]interface{}{
+		"error": map[string]string{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+// respondWithAPIError sends an error to a JSON client. /api/ routes get the
+// error envelope; /lookup_post keeps the flat {"error": "..."} shape that the
+// mobile app reads.
+func respondWithAPIError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	if isAPIPath(r) && wantsXML(r) {
+		respondWithXML(w, statusCode, apiErrorXML{Code: code, Message: message})
+		return
+	}
+	if isAPIPath(r) {
+		writeJSONError(w, statusCode, code, message)
+		return
+	}
+	respondWithJSON(w, statusCode, map[string]interface{}{
+		"error": message,
+	})
+}
+
 // respondWithJSON sends a JSON response
 func respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
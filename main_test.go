@@ -0,0 +1,743 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"mobile-name-lookup/db"
+)
+
+func TestMain(m *testing.M) {
+	// Handlers log every lookup; keep test output readable
+	baseLogger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+const (
+	testAPIKey   = "test-api-key"
+	testAdminKey = "test-admin-key"
+)
+
+// fakeProvider answers lookups from names, with fields as the extra
+// result fields, or with err when set, and counts the calls it gets
+type fakeProvider struct {
+	mu     sync.Mutex
+	names  map[string]string
+	fields map[string]interface{}
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) Lookup(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LookupResult{Name: p.names[req.Mobile], Fields: p.fields, Provider: "fake", StatusCode: http.StatusOK}, nil
+}
+
+func (p *fakeProvider) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// failingStore is a MemoryStore whose cache reads fail with err
+type failingStore struct {
+	*db.MemoryStore
+	err error
+}
+
+func (s failingStore) GetOverride(ctx context.Context, mobile string) (*db.Override, error) {
+	return nil, s.err
+}
+
+func (s failingStore) GetMobileRecord(ctx context.Context, mobile string) (*db.MobileRecord, error) {
+	return nil, s.err
+}
+
+// newTestService builds a lookup service with the defaults LoadConfig
+// would use
+func newTestService(store Store, provider NameLookupProvider) *lookupService {
+	return &lookupService{
+		database:         store,
+		provider:         provider,
+		stats:            NewLookupStats(),
+		nameMode:         nameNormalizeOff,
+		nameBlocklist:    newNameBlocklist(strings.Split(defaultNameBlocklist, ","), nil),
+		batchConcurrency: 5,
+		matchThreshold:   85,
+		logSampleRate:    1,
+	}
+}
+
+// newTestServer serves the lookup routes the way main wires them, minus
+// rate limiting
+func newTestServer(t *testing.T, service *lookupService) *httptest.Server {
+	t.Helper()
+
+	tmpl := template.Must(template.New("mobile").Parse(htmlTemplate))
+	render := func(w http.ResponseWriter, r *http.Request, data PageData) {
+		token, err := ensureCSRFToken(w, r)
+		if err != nil {
+			t.Errorf("ensureCSRFToken: %v", err)
+		}
+		data.CSRFToken = token
+		renderTemplate(w, r, tmpl, data)
+	}
+
+	apiKeys, adminKeys := []string{testAPIKey, testAdminKey}, []string{testAdminKey}
+	lookupHandler := newLookupHandler(service, render, "", adminKeys)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lookup_post", lookupHandler)
+	mux.HandleFunc("/api/v1/lookup", apiKeyMiddleware(lookupHandler, apiKeys))
+	mux.HandleFunc("/api/v1/batch", apiKeyMiddleware(batchLookupHandler(service), apiKeys))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// apiGet calls an API route with the test key, decoding the JSON body
+func apiGet(t *testing.T, server *httptest.Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testAPIKey)
+	return doJSON(t, req)
+}
+
+// apiPost posts body as JSON with the test key
+func apiPost(t *testing.T, server *httptest.Server, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", testAPIKey)
+	return doJSON(t, req)
+}
+
+func doJSON(t *testing.T, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding %s response: %v", req.URL.Path, err)
+	}
+	return resp.StatusCode, body
+}
+
+// errorCode returns the code of an API error envelope
+func errorCode(body map[string]interface{}) string {
+	envelope, _ := body["error"].(map[string]interface{})
+	code, _ := envelope["code"].(string)
+	return code
+}
+
+func TestAPILookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		cached      string // name already cached for the number
+		provider    *fakeProvider
+		store       func(*db.MemoryStore) Store
+		query       string
+		wantStatus  int
+		wantCode    string
+		wantName    string
+		wantSource  string
+		wantCalls   int
+		wantCachedN string // name cached afterwards
+	}{
+		{
+			name:       "cached number",
+			cached:     "Asha Rao",
+			provider:   &fakeProvider{},
+			query:      "mobile=9876543210",
+			wantStatus: http.StatusOK, wantName: "Asha Rao", wantSource: sourceDatabase,
+			wantCachedN: "Asha Rao",
+		},
+		{
+			name:       "uncached number",
+			provider:   &fakeProvider{names: map[string]string{"9876543210": "Ravi Kumar"}},
+			query:      "mobile=%2B91%2098765%2043210",
+			wantStatus: http.StatusOK, wantName: "Ravi Kumar", wantSource: sourceAPI, wantCalls: 1,
+			wantCachedN: "Ravi Kumar",
+		},
+		{
+			name:       "invalid number",
+			provider:   &fakeProvider{},
+			query:      "mobile=98765abcde",
+			wantStatus: http.StatusBadRequest, wantCode: errCodeMobileFormat,
+		},
+		{
+			name:       "missing number",
+			provider:   &fakeProvider{},
+			query:      "",
+			wantStatus: http.StatusBadRequest, wantCode: errCodeEmptyMobile,
+		},
+		{
+			name:       "no name found",
+			provider:   &fakeProvider{},
+			query:      "mobile=9876543210",
+			wantStatus: http.StatusNotFound, wantCode: errCodeNotFound, wantCalls: 1,
+		},
+		{
+			name:       "provider failure",
+			provider:   &fakeProvider{err: errors.New("connection reset")},
+			query:      "mobile=9876543210",
+			wantStatus: http.StatusInternalServerError, wantCode: errCodeUpstream, wantCalls: 1,
+		},
+		{
+			name:     "database failure",
+			provider: &fakeProvider{},
+			store: func(m *db.MemoryStore) Store {
+				return failingStore{m, errors.New("connection refused")}
+			},
+			query:      "mobile=9876543210",
+			wantStatus: http.StatusInternalServerError, wantCode: errCodeDatabase,
+		},
+		{
+			name:     "database past the deadline",
+			provider: &fakeProvider{},
+			store: func(m *db.MemoryStore) Store {
+				return failingStore{m, context.DeadlineExceeded}
+			},
+			query:      "mobile=9876543210",
+			wantStatus: http.StatusGatewayTimeout, wantCode: errCodeTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := db.NewMemoryStore()
+			if tt.cached != "" {
+				memory.SaveMobileRecord(context.Background(), "9876543210", tt.cached, "")
+			}
+			var store Store = memory
+			if tt.store != nil {
+				store = tt.store(memory)
+			}
+			server := newTestServer(t, newTestService(store, tt.provider))
+
+			status, body := apiGet(t, server, "/api/v1/lookup?"+tt.query)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %v)", status, tt.wantStatus, body)
+			}
+			if code := errorCode(body); code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", code, tt.wantCode)
+			}
+			if tt.wantName != "" {
+				result, _ := body["result"].(map[string]interface{})
+				if result["mobile_linked_name"] != tt.wantName || body["source"] != tt.wantSource {
+					t.Errorf("got name %v from %v, want %q from %q", result["mobile_linked_name"], body["source"], tt.wantName, tt.wantSource)
+				}
+			}
+			if calls := tt.provider.callCount(); calls != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantCachedN != "" {
+				record, _ := memory.GetMobileRecord(context.Background(), "9876543210")
+				if record == nil || record.Name != tt.wantCachedN {
+					t.Errorf("cached record = %+v, want name %q", record, tt.wantCachedN)
+				}
+			}
+		})
+	}
+}
+
+func TestAPILookupRequiresKey(t *testing.T) {
+	server := newTestServer(t, newTestService(db.NewMemoryStore(), &fakeProvider{}))
+
+	for _, key := range []string{"", "wrong-key"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/lookup?mobile=9876543210", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		status, body := doJSON(t, req)
+		if status != http.StatusUnauthorized || errorCode(body) != errCodeUnauthorized {
+			t.Errorf("key %q: got %d %v, want 401 %s", key, status, body, errCodeUnauthorized)
+		}
+	}
+}
+
+func TestAPILookupRefreshNeedsAdminKey(t *testing.T) {
+	provider := &fakeProvider{names: map[string]string{"9876543210": "Ravi Kumar"}}
+	store := db.NewMemoryStore()
+	store.SaveMobileRecord(context.Background(), "9876543210", "Old Name", "")
+	server := newTestServer(t, newTestService(store, provider))
+
+	status, body := apiPost(t, server, "/api/v1/lookup", `{"mobile": "9876543210", "refresh": true}`)
+	if status != http.StatusForbidden || errorCode(body) != errCodeForbidden {
+		t.Fatalf("refresh with a plain key: got %d %v, want 403", status, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/lookup", strings.NewReader(`{"mobile": "9876543210", "refresh": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", testAdminKey)
+	status, body = doJSON(t, req)
+	if status != http.StatusOK || body["source"] != sourceAPI {
+		t.Fatalf("refresh with an admin key: got %d %v, want 200 from the API", status, body)
+	}
+	if record, _ := store.GetMobileRecord(context.Background(), "9876543210"); record.Name != "Ravi Kumar" {
+		t.Errorf("cached name after refresh = %q, want Ravi Kumar", record.Name)
+	}
+}
+
+func TestLegacyLookupPostJSON(t *testing.T) {
+	provider := &fakeProvider{names: map[string]string{"9876543210": "Ravi Kumar"}}
+	server := newTestServer(t, newTestService(db.NewMemoryStore(), provider))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantName   string
+		wantError  bool
+	}{
+		{name: "found", body: `{"mobile": "9876543210"}`, wantStatus: http.StatusOK, wantName: "Ravi Kumar"},
+		// Legacy clients keep 200 with an empty name rather than 404
+		{name: "not found", body: `{"mobile": "9123456780"}`, wantStatus: http.StatusOK, wantName: ""},
+		{name: "invalid", body: `{"mobile": "12"}`, wantStatus: http.StatusBadRequest, wantError: true},
+		{name: "malformed JSON", body: `{"mobile":`, wantStatus: http.StatusBadRequest, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/lookup_post", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			status, body := doJSON(t, req)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %v)", status, tt.wantStatus, body)
+			}
+			// The legacy error shape is a flat string
+			if _, isString := body["error"].(string); isString != tt.wantError {
+				t.Errorf("flat error present = %v, want %v (body %v)", isString, tt.wantError, body)
+			}
+			if !tt.wantError {
+				result, _ := body["result"].(map[string]interface{})
+				if result["mobile_linked_name"] != tt.wantName {
+					t.Errorf("name = %v, want %q", result["mobile_linked_name"], tt.wantName)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupPostForm(t *testing.T) {
+	provider := &fakeProvider{names: map[string]string{"9876543210": "Ravi Kumar"}}
+	server := newTestServer(t, newTestService(db.NewMemoryStore(), provider))
+
+	post := func(form url.Values, cookie *http.Cookie) (int, string) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/lookup_post", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		page, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(page)
+	}
+	cookie := &http.Cookie{Name: csrfCookieName, Value: "form-token"}
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		cookie     *http.Cookie
+		wantStatus int
+		wantText   string
+	}{
+		{
+			name:       "found",
+			form:       url.Values{"mobile": {"98765 43210"}, csrfFieldName: {"form-token"}},
+			cookie:     cookie,
+			wantStatus: http.StatusOK, wantText: "Ravi Kumar",
+		},
+		{
+			name:       "not found",
+			form:       url.Values{"mobile": {"9123456780"}, csrfFieldName: {"form-token"}},
+			cookie:     cookie,
+			wantStatus: http.StatusOK, wantText: "No name found",
+		},
+		{
+			name:       "invalid number",
+			form:       url.Values{"mobile": {"98765"}, csrfFieldName: {"form-token"}},
+			cookie:     cookie,
+			wantStatus: http.StatusOK, wantText: "Invalid mobile number",
+		},
+		{
+			name:       "several numbers",
+			form:       url.Values{"mobiles": {"9876543210\n98765"}, csrfFieldName: {"form-token"}},
+			cookie:     cookie,
+			wantStatus: http.StatusOK, wantText: "Ravi Kumar",
+		},
+		{
+			name:       "missing CSRF token",
+			form:       url.Values{"mobile": {"9876543210"}},
+			cookie:     cookie,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "CSRF token without cookie",
+			form:       url.Values{"mobile": {"9876543210"}, csrfFieldName: {"form-token"}},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, page := post(tt.form, tt.cookie)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if !strings.Contains(page, tt.wantText) {
+				t.Errorf("page does not contain %q", tt.wantText)
+			}
+		})
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    *fakeProvider
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantSummary map[string]float64
+		wantResults []string // status of each result
+	}{
+		{
+			name:        "mixed outcomes",
+			provider:    &fakeProvider{names: map[string]string{"9876543210": "Ravi Kumar"}},
+			body:        `{"mobiles": ["9876543210", "9123456780", "12"]}`,
+			wantStatus:  http.StatusOK,
+			wantSummary: map[string]float64{batchStatusOK: 1, batchStatusNotFound: 1, batchStatusFailed: 1},
+			wantResults: []string{batchStatusOK, batchStatusNotFound, batchStatusFailed},
+		},
+		{
+			name:       "every lookup failing upstream",
+			provider:   &fakeProvider{err: errors.New("connection reset")},
+			body:       `{"mobiles": ["9876543210", "9123456780"]}`,
+			wantStatus: http.StatusInternalServerError, wantCode: errCodeUpstream,
+			wantSummary: map[string]float64{batchStatusOK: 0, batchStatusFailed: 2},
+			wantResults: []string{batchStatusFailed, batchStatusFailed},
+		},
+		{
+			name:       "empty list",
+			provider:   &fakeProvider{},
+			body:       `{"mobiles": []}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidRequest,
+		},
+		{
+			name:       "malformed body",
+			provider:   &fakeProvider{},
+			body:       `{"mobiles": "9876543210"}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, newTestService(db.NewMemoryStore(), tt.provider))
+
+			status, body := apiPost(t, server, "/api/v1/batch", tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %v)", status, tt.wantStatus, body)
+			}
+			if code := errorCode(body); code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", code, tt.wantCode)
+			}
+			if tt.wantSummary == nil {
+				return
+			}
+
+			summary, _ := body["summary"].(map[string]interface{})
+			for key, want := range tt.wantSummary {
+				if summary[key] != want {
+					t.Errorf("summary[%s] = %v, want %v", key, summary[key], want)
+				}
+			}
+			results, _ := body["results"].([]interface{})
+			if len(results) != len(tt.wantResults) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.wantResults))
+			}
+			for i, want := range tt.wantResults {
+				result, _ := results[i].(map[string]interface{})
+				if result["status"] != want {
+					t.Errorf("result %d status = %v, want %s", i, result["status"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsPlausibleNumber(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   bool
+	}{
+		{"9876501234", true},
+		{"9123456780", true},
+		{"9999999998", true},
+		{"9999999999", false},
+		{"0000000000", false},
+		{"6789012345", false}, // ascending, wrapping past 9
+		{"9876543210", false},
+		{"3210987654", false}, // descending, wrapping past 0
+		{"9", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if got := isPlausibleNumber(tt.digits); got != tt.want {
+			t.Errorf("isPlausibleNumber(%q) = %v, want %v", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestRejectSuspiciousSkipsProvider(t *testing.T) {
+	provider := &fakeProvider{}
+	service := newTestService(db.NewMemoryStore(), provider)
+	service.rejectSuspicious = true
+	server := newTestServer(t, service)
+
+	status, body := apiGet(t, server, "/api/v1/lookup?mobile=9999999999")
+	if status != http.StatusBadRequest || errorCode(body) != errCodeInvalidMobile {
+		t.Fatalf("got %d %v, want 400 %s", status, body, errCodeInvalidMobile)
+	}
+	if calls := provider.callCount(); calls != 0 {
+		t.Errorf("provider called %d times for a fake number", calls)
+	}
+}
+
+func TestMaxBodyMiddleware(t *testing.T) {
+	service := newTestService(db.NewMemoryStore(), &fakeProvider{names: map[string]string{"9876543210": "Ravi Kumar"}})
+	render := func(w http.ResponseWriter, r *http.Request, data PageData) {}
+	lookupHandler := newLookupHandler(service, render, "", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lookup_post", lookupHandler)
+	mux.HandleFunc("/api/v1/lookup", lookupHandler)
+	mux.HandleFunc("/api/v1/batch", batchLookupHandler(service))
+	handler := maxBodyMiddleware(mux, 64)
+
+	padding := strings.Repeat(" ", 64)
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		accept      string
+		body        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{"lookup within limit", "/api/v1/lookup", "application/json", "", `{"mobile": "9876543210"}`, http.StatusOK, ""},
+		{"lookup over limit", "/api/v1/lookup", "application/json", "", `{"mobile": "9876543210"` + padding + `}`, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge},
+		{"form over limit", "/api/v1/lookup", "application/x-www-form-urlencoded", "", "mobile=9876543210&pad=" + padding, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge},
+		{"batch over limit", "/api/v1/batch", "application/json", "", `{"mobiles": ["9876543210", "9876543210", "9876543210", "9876543210"]}`, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge},
+		{"legacy JSON over limit", "/lookup_post", "application/json", "application/json", `{"mobile": "9876543210"` + padding + `}`, http.StatusRequestEntityTooLarge, ""},
+		{"legacy form over limit", "/lookup_post", "application/x-www-form-urlencoded", "", "mobile=9876543210&pad=" + padding, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode != "" && !strings.Contains(rec.Body.String(), `"`+tt.wantCode+`"`) {
+				t.Errorf("body %s does not carry code %s", rec.Body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDigitapClientResponseLimit(t *testing.T) {
+	// A response of exactly n bytes with the name at the front
+	response := func(n int) string {
+		body := `{"result": {"mobile_linked_name": "Ravi Kumar"}, "pad": "`
+		return body + strings.Repeat("x", n-len(body)-2) + `"}`
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		limit    int64
+		wantErr  bool
+		wantName string
+	}{
+		{name: "under the limit", body: response(100), limit: 200, wantName: "Ravi Kumar"},
+		{name: "at the limit", body: response(200), limit: 200, wantName: "Ravi Kumar"},
+		{name: "one byte over", body: response(201), limit: 200, wantErr: true},
+		{name: "default limit", body: response(defaultMaxResponseBytes + 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewDigitapClient(server.URL, "token")
+			client.MaxResponseBytes = tt.limit
+
+			result, err := client.Lookup(context.Background(), LookupRequest{Mobile: "9876543210"})
+			if tt.wantErr {
+				var perr *ProviderError
+				if !errors.As(err, &perr) || !strings.Contains(err.Error(), "exceeds") {
+					t.Fatalf("Lookup error = %v, want an oversized response error", err)
+				}
+				// An oversized body won't shrink on a retry
+				if calls != 1 {
+					t.Errorf("called the provider %d times, want 1", calls)
+				}
+				return
+			}
+			if err != nil || result.Name != tt.wantName {
+				t.Fatalf("Lookup = %+v, %v; want %q", result, err, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestParseExtraFields(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{data: `{"consent": "Y", "purpose": "KYC"}`, want: 2},
+		{data: `{"consent": {"given": true}}`, want: 1},
+		{data: `{}`, want: 0},
+		{data: `["consent"]`, wantErr: true},
+		{data: `consent=Y`, wantErr: true},
+		{data: `{"mobile": "9876543210"}`, wantErr: true},
+		{data: `{"client_ref_num": "x", "consent": "Y"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		fields, err := parseExtraFields(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseExtraFields(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if len(fields) != tt.want {
+			t.Errorf("parseExtraFields(%s) = %v, want %d fields", tt.data, fields, tt.want)
+		}
+	}
+}
+
+func TestDigitapClientSendsExtraFields(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		io.WriteString(w, `{"result": {"mobile_linked_name": "Ravi Kumar"}}`)
+	}))
+	defer server.Close()
+
+	client := NewDigitapClient(server.URL, "token")
+	client.ExtraFields = map[string]interface{}{"consent": "Y", "purpose": "KYC"}
+	if _, err := client.Lookup(context.Background(), LookupRequest{Mobile: "9876543210", Name: "Ravi", ClientRef: "ref-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"consent": "Y", "purpose": "KYC", "mobile": "9876543210", "name": "Ravi", "client_ref_num": "ref-1"}
+	if len(sent) != len(want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+	for key, value := range want {
+		if sent[key] != value {
+			t.Errorf("sent %s = %v, want %v", key, sent[key], value)
+		}
+	}
+}
+
+func TestLookupPostFormCleansVerifyName(t *testing.T) {
+	provider := &namesSeenProvider{}
+	server := newTestServer(t, newTestService(db.NewMemoryStore(), provider))
+
+	form := url.Values{"mobile": {"9876543210"}, "name": {"  Ravi\t\u00a0Kumar "}, csrfFieldName: {"form-token"}}
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/lookup_post", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "form-token"})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(provider.names) != 1 || provider.names[0] != "Ravi Kumar" {
+		t.Errorf("provider got names %q, want [Ravi Kumar]", provider.names)
+	}
+}
+
+// namesSeenProvider records the names it is asked to verify
+type namesSeenProvider struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (p *namesSeenProvider) Lookup(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names = append(p.names, req.Name)
+	return &LookupResult{Name: "Ravi Kumar", Provider: "fake", StatusCode: http.StatusOK}, nil
+}
+
+func TestAcceptedNameMinConfidence(t *testing.T) {
+	score := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name   string
+		result *LookupResult
+		want   string
+	}{
+		{name: "confident", result: &LookupResult{Name: "Ravi Kumar", Fields: map[string]interface{}{"confidence": 90.0}}, want: "Ravi Kumar"},
+		{name: "doubtful", result: &LookupResult{Name: "Ravi Kumar", Fields: map[string]interface{}{"confidence": 40.0}}},
+		{name: "no confidence field", result: &LookupResult{Name: "Ravi Kumar"}, want: "Ravi Kumar"},
+		// A poor match for the name being verified says nothing about the
+		// linked name
+		{name: "low match score", result: &LookupResult{Name: "Ravi Kumar", NameMatchScore: score(10)}, want: "Ravi Kumar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(db.NewMemoryStore(), &fakeProvider{})
+			service.minConfidence = 70
+			if got := service.acceptedName("9876543210", tt.result); got != tt.want {
+				t.Errorf("acceptedName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "net/http"
+
+// defaultMaintenanceMessage is shown while MAINTENANCE_MODE is on and
+// MAINTENANCE_MESSAGE is unset
+const defaultMaintenanceMessage = "Lookups are temporarily unavailable for scheduled maintenance. Please try again later."
+
+// statusWriter sends status in place of whatever status the wrapped handler
+// writes, so a page rendered as usual goes out as an error
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusWriter) WriteHeader(int) {
+	if !s.wroteHeader {
+		s.wroteHeader = true
+		s.ResponseWriter.WriteHeader(s.status)
+	}
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	s.WriteHeader(s.status)
+	return s.ResponseWriter.Write(p)
+}
+
+// Middleware answering 503 with message instead of calling next, for routes
+// that can reach the provider while MAINTENANCE_MODE is on. Forms get the
+// page back with the message; GETs of form routes pass through, since they
+// only show or redirect to the form.
+func maintenanceMiddleware(next http.HandlerFunc, message string, render renderFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case isAPIRequest(r):
+			respondWithAPIError(w, r, http.StatusServiceUnavailable, errCodeMaintenance, message)
+		case r.Method == http.MethodGet:
+			next(w, r)
+		default:
+			render(&statusWriter{ResponseWriter: w, status: http.StatusServiceUnavailable}, r, PageData{Error: message})
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"hash/fnv"
+	"net/http"
+)
+
+// Names the mock provider builds fake names from
+var (
+	mockFirstNames = []string{"Aarav", "Ananya", "Vihaan", "Diya", "Arjun", "Isha", "Kabir", "Meera", "Rohan", "Saanvi"}
+	mockLastNames  = []string{"Sharma", "Patel", "Iyer", "Reddy", "Gupta", "Nair", "Singh", "Das", "Mehta", "Joshi"}
+)
+
+// mockLookupResponse answers a lookup without calling the network, for
+// local development without credentials. The name is derived from a hash
+// of the number, so the same number always gets the same name, and about
+// one number in ten gets no name at all to exercise the not-found path.
+// When a name to verify is sent it is compared with the fake name.
+func mockLookupResponse(mobile, name string) *MobileNameLookupResponse {
+	h := fnv.New32a()
+	h.Write([]byte(mobile))
+	sum := h.Sum32()
+
+	response := &MobileNameLookupResponse{Status: "success", StatusCode: http.StatusOK}
+	if sum%10 == 0 {
+		return response
+	}
+
+	fake := mockFirstNames[sum/10%uint32(len(mockFirstNames))] + " " + mockLastNames[sum/100%uint32(len(mockLastNames))]
+	response.Result.MobileLinkedName = fake
+	if name != "" {
+		score := tokenSortRatio(fake, name)
+		match := score >= 85
+		response.Result.NameMatch, response.Result.NameMatchScore = &match, &score
+	}
+	return response
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Name normalization modes selected by NORMALIZE_NAMES
+const (
+	nameNormalizeOff   = "off"
+	nameNormalizeOn    = "on"
+	nameNormalizeTitle = "title"
+)
+
+func validNameMode(mode string) bool {
+	switch mode {
+	case nameNormalizeOff, nameNormalizeOn, nameNormalizeTitle:
+		return true
+	}
+	return false
+}
+
+// normalizeName trims and collapses internal whitespace ("on"), and also
+// title-cases each word ("title"), e.g. "  JOHN   DOE " becomes "John Doe"
+func normalizeName(name, mode string) string {
+	if mode == nameNormalizeOff {
+		return name
+	}
+
+	name = strings.Join(strings.Fields(name), " ")
+	if mode == nameNormalizeTitle {
+		name = titleCase(name)
+	}
+	return name
+}
+
+// defaultNameBlocklist is what providers send when they have no name.
+// NAME_BLOCKLIST replaces it.
+const defaultNameBlocklist = "NA,N/A,N.A.,NULL,NIL,NONE,UNKNOWN,UNDEFINED,NOT AVAILABLE,NO NAME"
+
+// nameBlocklist recognizes placeholder names from the provider, which are
+// treated as no name at all rather than cached. Names are compared
+// ignoring case and extra whitespace. A nil *nameBlocklist still blocks
+// names without a single letter, such as a phone number sent as the name.
+type nameBlocklist struct {
+	values  map[string]bool
+	pattern *regexp.Regexp
+}
+
+// newNameBlocklist blocks each of values and, if pattern is set, every name
+// it matches
+func newNameBlocklist(values []string, pattern *regexp.Regexp) *nameBlocklist {
+	b := &nameBlocklist{values: make(map[string]bool, len(values)), pattern: pattern}
+	for _, value := range values {
+		if value = blocklistKey(value); value != "" {
+			b.values[value] = true
+		}
+	}
+	return b
+}
+
+// Blocked reports whether name is a placeholder rather than a name
+func (b *nameBlocklist) Blocked(name string) bool {
+	if !strings.ContainsFunc(name, unicode.IsLetter) {
+		return true
+	}
+	if b == nil {
+		return false
+	}
+	key := blocklistKey(name)
+	return b.values[key] || (b.pattern != nil && b.pattern.MatchString(key))
+}
+
+func blocklistKey(name string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(name), " "))
+}
+
+// cleanVerifyName trims a name to verify and collapses runs of whitespace,
+// including pasted tabs and non-breaking spaces, to single spaces. Case is
+// left alone. An empty result means no verification.
+func cleanVerifyName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// titleCase upper-cases the first letter of each word and lower-cases the
+// rest. Hyphens and apostrophes start a new word, so "O'BRIEN-SMITH" becomes
+// "O'Brien-Smith".
+func titleCase(s string) string {
+	runes := []rune(s)
+	start := true
+	for i, r := range runes {
+		if start {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+		start = r == ' ' || r == '-' || r == '\''
+	}
+	return string(runes)
+}
+
+// tokenSortRatio scores how similar two names are from 0 to 100, ignoring
+// case, punctuation and word order: both are reduced to sorted lower-case
+// words before comparing, so "DOE, John" and "john doe" score 100. The
+// score is 2*LCS/(len(a)+len(b)) over the normalized strings.
+func tokenSortRatio(a, b string) float64 {
+	a, b = sortedTokens(a), sortedTokens(b)
+	if a == "" && b == "" {
+		return 100
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	return 200 * float64(longestCommonSubsequence(ra, rb)) / float64(len(ra)+len(rb))
+}
+
+// sortedTokens lower-cases s, splits it into words of letters and digits
+// and joins them back in sorted order
+func sortedTokens(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// longestCommonSubsequence returns the length of the longest common
+// subsequence of a and b
+func longestCommonSubsequence(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				curr[j] = prev[j-1] + 1
+			case prev[j] >= curr[j-1]:
+				curr[j] = prev[j]
+			default:
+				curr[j] = curr[j-1]
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"mobile-name-lookup/db"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		mode string
+		want string
+	}{
+		{"off keeps spacing", "  JOHN   DOE ", nameNormalizeOff, "  JOHN   DOE "},
+		{"on collapses spacing", "  JOHN   DOE ", nameNormalizeOn, "JOHN DOE"},
+		{"on keeps case", "john\tdoe", nameNormalizeOn, "john doe"},
+		{"title", "  JOHN   DOE ", nameNormalizeTitle, "John Doe"},
+		{"title hyphen and apostrophe", "O'BRIEN-SMITH mary", nameNormalizeTitle, "O'Brien-Smith Mary"},
+		{"title non-ASCII", "ÉLODIE  ŁUKASZ", nameNormalizeTitle, "Élodie Łukasz"},
+		{"empty", "   ", nameNormalizeTitle, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeName(tt.in, tt.mode); got != tt.want {
+				t.Errorf("normalizeName(%q, %q) = %q, want %q", tt.in, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidNameMode(t *testing.T) {
+	for mode, want := range map[string]bool{"off": true, "on": true, "title": true, "TITLE": false, "": false, "upper": false} {
+		if got := validNameMode(mode); got != want {
+			t.Errorf("validNameMode(%q) = %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestCleanVerifyName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Ravi Kumar", "Ravi Kumar"},
+		{"surrounding space", "  Ravi Kumar  ", "Ravi Kumar"},
+		{"runs of space", "Ravi    Kumar", "Ravi Kumar"},
+		{"pasted tab and newline", "Ravi\tKumar\r\n", "Ravi Kumar"},
+		{"non-breaking space", "Ravi\u00a0Kumar", "Ravi Kumar"},
+		{"case kept", "RAVI kumar", "RAVI kumar"},
+		{"only space means no verification", " \t  ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanVerifyName(tt.in); got != tt.want {
+				t.Errorf("cleanVerifyName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameBlocklist(t *testing.T) {
+	builtIn := newNameBlocklist(strings.Split(defaultNameBlocklist, ","), nil)
+	custom := newNameBlocklist([]string{"TEST USER", " "}, regexp.MustCompile(`^CUSTOMER \d+$`))
+	var none *nameBlocklist
+
+	tests := []struct {
+		name      string
+		blocklist *nameBlocklist
+		in        string
+		want      bool
+	}{
+		{"built-in NA", builtIn, "NA", true},
+		{"built-in ignores case and spacing", builtIn, "  not   available ", true},
+		{"built-in N/A", builtIn, "n/a", true},
+		{"built-in NULL", builtIn, "Null", true},
+		{"real name", builtIn, "Ravi Kumar", false},
+		{"name containing a blocked word", builtIn, "Nana Patil", false},
+		{"digits only", builtIn, "9876543210", true},
+		{"punctuation only", builtIn, "--", true},
+		{"empty", builtIn, "", true},
+		{"custom value", custom, "test  user", true},
+		{"custom replaces built-ins", custom, "NA", false},
+		{"custom pattern", custom, "customer 42", true},
+		{"custom pattern anchored", custom, "customer 42 rao", false},
+		{"nil still blocks digits", none, "9876543210", true},
+		{"nil lets names through", none, "NA", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.blocklist.Blocked(tt.in); got != tt.want {
+				t.Errorf("Blocked(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockedProviderNameIsNotCached(t *testing.T) {
+	store := db.NewMemoryStore()
+	provider := &fakeProvider{names: map[string]string{"9876543210": "N/A"}}
+	server := newTestServer(t, newTestService(store, provider))
+
+	status, body := apiGet(t, server, "/api/v1/lookup?mobile=9876543210")
+	if status != http.StatusNotFound || errorCode(body) != errCodeNotFound {
+		t.Fatalf("got %d %v, want 404 %s", status, body, errCodeNotFound)
+	}
+	if record, _ := store.GetMobileRecord(context.Background(), "9876543210"); record != nil {
+		t.Errorf("cached the placeholder name: %+v", record)
+	}
+}
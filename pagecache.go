@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// formPageETag returns the ETag of the empty form page. The page is the
+// template filled in with the route prefix, branding, static asset version
+// and the visitor's CSRF token, so the tag changes when a deploy changes any
+// of them and differs per visitor. It is weak because gzipMiddleware may
+// re-encode the body.
+func formPageETag(data PageData) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%+v", htmlTemplate, data.BasePath, data.CSRFToken, data.AssetVersion, data.Brand)))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// setFormPageCacheHeaders lets browsers cache the empty form page and
+// revalidate it with If-None-Match, answering 304 when it is unchanged.
+// The page embeds the visitor's CSRF token, so it is private to them and
+// varies by cookie rather than being shared through a CDN. It reports
+// whether the 304 was sent.
+func setFormPageCacheHeaders(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Cookie")
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
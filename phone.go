@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// countryRule describes the national mobile numbers of one country. Every
+// supported country uses 10-digit national numbers, which is what the
+// mobile columns store.
+type countryRule struct {
+	Name   string
+	Mobile *regexp.Regexp
+}
+
+// countryRules maps calling codes to their national number format
+var countryRules = map[string]countryRule{
+	"91": {Name: "India", Mobile: regexp.MustCompile(`^[6-9]\d{9}$`)},
+	"1":  {Name: "US/Canada", Mobile: regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`)},
+	"44": {Name: "UK", Mobile: regexp.MustCompile(`^7\d{9}$`)},
+}
+
+// defaultCountryCode is the calling code assumed for numbers entered without
+// an explicit +<code> prefix, and the only country lookups are served for
+var defaultCountryCode = "91"
+
+var nonDigits = regexp.MustCompile(`[^\d]`)
+
+// maxPhoneInputLength bounds a typed number, separators included. It
+// matches the raw_mobile column, so a number padded with separators can't
+// pass validation, get looked up and then fail to save.
+const maxPhoneInputLength = 64
+
+// phoneChars are the characters a typed number may contain: digits and the
+// usual separators. Anything else, such as letters, means the input is not
+// just a number and its digits are not trusted.
+var phoneChars = regexp.MustCompile(`^[\d\s+\-.()/]*$`)
+
+// Validation errors returned by parsePhoneNumber and cleanPhoneNumber,
+// wrapped with details. Match them with errors.Is.
+var (
+	ErrEmptyNumber        = errors.New("no digits found in phone number")
+	ErrBadLength          = errors.New("invalid phone number length")
+	ErrBadFormat          = errors.New("invalid mobile number format")
+	ErrUnsupportedCountry = errors.New("unsupported country code")
+	ErrAmbiguousNumber    = errors.New("ambiguous country code")
+)
+
+// phoneNumber is a number split into its calling code and national number
+type phoneNumber struct {
+	CountryCode string
+	National    string
+}
+
+// parsePhoneNumber parses phone into a calling code and national number. An
+// explicit "+<code>" or "00<code>" prefix is honored and the rest is checked
+// against that country's format. A bare 10-digit number, or one with a
+// trunk prefix 0, is read under the rules for defaultCountry; a longer bare
+// number must start with exactly one fitting country code.
+func parsePhoneNumber(phone, defaultCountry string) (phoneNumber, error) {
+	if len(phone) > maxPhoneInputLength {
+		return phoneNumber{}, fmt.Errorf("%w: more than %d characters", ErrBadLength, maxPhoneInputLength)
+	}
+	trimmed := strings.TrimSpace(phone)
+	digits := nonDigits.ReplaceAllString(trimmed, "")
+
+	if len(digits) == 0 {
+		return phoneNumber{}, ErrEmptyNumber
+	}
+	if !phoneChars.MatchString(trimmed) {
+		return phoneNumber{}, fmt.Errorf("%w: only digits, spaces and + - . ( ) / are allowed", ErrBadFormat)
+	}
+
+	if strings.HasPrefix(trimmed, "+") || strings.HasPrefix(trimmed, "00") {
+		if !strings.HasPrefix(trimmed, "+") {
+			digits = digits[2:]
+		}
+		for code := range countryRules {
+			if strings.HasPrefix(digits, code) {
+				return checkNational(code, digits[len(code):])
+			}
+		}
+		return phoneNumber{}, ErrUnsupportedCountry
+	}
+
+	// Without a +, a leading country code is only taken off when that
+	// reading is unambiguous: exactly one known code fits and leaves a
+	// valid national number for its country. An Indian number mistyped
+	// with a leading 1 reads as a US/Canada number and is rejected rather
+	// than accepted as Indian.
+	if len(digits) > 10 {
+		if strings.HasPrefix(digits, "0") && len(digits) == 11 {
+			return checkNational(defaultCountry, digits[1:]) // Remove the trunk prefix 0
+		}
+
+		var matches []phoneNumber
+		for code := range countryRules {
+			if strings.HasPrefix(digits, code) && len(digits) == len(code)+10 {
+				if number, err := checkNational(code, digits[len(code):]); err == nil {
+					matches = append(matches, number)
+				}
+			}
+		}
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+			// Too long for any country; fails the length check below.
+			// Keeping the last 10 digits would turn a mistyped number
+			// into a different, valid-looking one.
+		default:
+			return phoneNumber{}, fmt.Errorf("%w: add a + before the country code", ErrAmbiguousNumber)
+		}
+	}
+
+	return checkNational(defaultCountry, digits)
+}
+
+// checkNational validates a national number against the format for code
+func checkNational(code, national string) (phoneNumber, error) {
+	if len(national) != 10 {
+		return phoneNumber{}, fmt.Errorf("%w: %d digits (expected 10)", ErrBadLength, len(national))
+	}
+	if !countryRules[code].Mobile.MatchString(national) {
+		return phoneNumber{}, fmt.Errorf("%w for %s", ErrBadFormat, countryRules[code].Name)
+	}
+	return phoneNumber{CountryCode: code, National: national}, nil
+}
+
+// cleanPhoneNumber removes all non-digit characters and handles country
+// codes, returning the 10-digit national number. Numbers from a country
+// other than defaultCountryCode are rejected since lookups and the cache
+// only cover one country.
+func cleanPhoneNumber(phone string) (string, error) {
+	number, err := parsePhoneNumber(phone, defaultCountryCode)
+	if err != nil {
+		return "", err
+	}
+	if number.CountryCode != defaultCountryCode {
+		return "", fmt.Errorf("%w: +%s numbers are not supported; lookups cover +%s only", ErrUnsupportedCountry, number.CountryCode, defaultCountryCode)
+	}
+	return number.National, nil
+}
+
+// mobileErrorCode maps a cleanPhoneNumber error to its API error code
+func mobileErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrEmptyNumber):
+		return errCodeEmptyMobile
+	case errors.Is(err, ErrBadLength):
+		return errCodeMobileLength
+	case errors.Is(err, ErrBadFormat):
+		return errCodeMobileFormat
+	case errors.Is(err, ErrUnsupportedCountry), errors.Is(err, ErrAmbiguousNumber):
+		return errCodeUnsupportedCountry
+	default:
+		return errCodeInvalidMobile
+	}
+}
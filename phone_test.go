@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanPhoneNumber(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultCountry string
+		input          string
+		want           string
+		wantErr        error
+	}{
+		// Indian numbers under the default country
+		{name: "bare", input: "9876543210", want: "9876543210"},
+		{name: "plus 91", input: "+919876543210", want: "9876543210"},
+		{name: "plus 91 spaced", input: "+91 98765 43210", want: "9876543210"},
+		{name: "00 91", input: "0091 9876543210", want: "9876543210"},
+		{name: "91 without plus", input: "919876543210", want: "9876543210"},
+		{name: "trunk prefix 0", input: "09876543210", want: "9876543210"},
+		{name: "spaced", input: "98765 43210", want: "9876543210"},
+		{name: "dashed", input: "98765-43210", want: "9876543210"},
+		{name: "brackets and dots", input: "(987) 654.3210", want: "9876543210"},
+		{name: "surrounding whitespace", input: "  9876543210\n", want: "9876543210"},
+
+		// Rejected input
+		{name: "empty", input: "", wantErr: ErrEmptyNumber},
+		{name: "only separators", input: " - ", wantErr: ErrEmptyNumber},
+		{name: "too short", input: "98765", wantErr: ErrBadLength},
+		{name: "too long", input: "98765432101234", wantErr: ErrBadLength},
+		{name: "91 too long", input: "+91 98765432101", wantErr: ErrBadLength},
+		{name: "over the input limit", input: "98765 " + strings.Repeat("-", 60) + "43210", wantErr: ErrBadLength},
+		{name: "letters", input: "98765abcde", wantErr: ErrBadFormat},
+		{name: "letters around digits", input: "call 9876543210", wantErr: ErrBadFormat},
+		{name: "not a mobile prefix", input: "5876543210", wantErr: ErrBadFormat},
+		{name: "unknown country code", input: "+33 612345678", wantErr: ErrUnsupportedCountry},
+		{name: "other supported country", input: "+44 7911 123456", wantErr: ErrUnsupportedCountry},
+		// 1 and 10 digits reads as a US/Canada number, not an Indian one
+		// with a stray digit
+		{name: "leading 1 on an Indian number", input: "19876543210", wantErr: ErrUnsupportedCountry},
+
+		// DEFAULT_COUNTRY_CODE=44
+		{name: "UK bare", defaultCountry: "44", input: "7911123456", want: "7911123456"},
+		{name: "UK trunk prefix", defaultCountry: "44", input: "07911 123456", want: "7911123456"},
+		{name: "UK plus 44", defaultCountry: "44", input: "+44 7911 123456", want: "7911123456"},
+		{name: "UK 44 without plus", defaultCountry: "44", input: "447911123456", want: "7911123456"},
+		{name: "UK Indian number", defaultCountry: "44", input: "9876543210", wantErr: ErrBadFormat},
+		{name: "UK plus 91", defaultCountry: "44", input: "+91 9876543210", wantErr: ErrUnsupportedCountry},
+
+		// DEFAULT_COUNTRY_CODE=1
+		{name: "US bare", defaultCountry: "1", input: "(212) 555-0123", want: "2125550123"},
+		{name: "US plus 1", defaultCountry: "1", input: "+1 212 555 0123", want: "2125550123"},
+		{name: "US 1 without plus", defaultCountry: "1", input: "1 212 555 0123", want: "2125550123"},
+		{name: "US exchange starting 1", defaultCountry: "1", input: "2121550123", wantErr: ErrBadFormat},
+		{name: "US plus 91", defaultCountry: "1", input: "+919876543210", wantErr: ErrUnsupportedCountry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.defaultCountry != "" {
+				saved := defaultCountryCode
+				defaultCountryCode = tt.defaultCountry
+				t.Cleanup(func() { defaultCountryCode = saved })
+			}
+
+			got, err := cleanPhoneNumber(tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("cleanPhoneNumber(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cleanPhoneNumber(%q) error = %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("cleanPhoneNumber(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMobileErrorCode(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", errCodeEmptyMobile},
+		{"98765", errCodeMobileLength},
+		{"98765abcde", errCodeMobileFormat},
+		{"+44 7911 123456", errCodeUnsupportedCountry},
+	}
+	for _, tt := range tests {
+		_, err := cleanPhoneNumber(tt.input)
+		if got := mobileErrorCode(err); got != tt.want {
+			t.Errorf("mobileErrorCode for %q = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParsePhoneNumberDetectsCountry(t *testing.T) {
+	tests := []struct {
+		input          string
+		defaultCountry string
+		wantCode       string
+		wantNational   string
+		wantErr        error
+	}{
+		{"+91 98765 43210", "91", "91", "9876543210", nil},
+		{"+44 7911 123456", "91", "44", "7911123456", nil},
+		{"0044 7911 123456", "91", "44", "7911123456", nil},
+		{"+1 (212) 555-0123", "91", "1", "2125550123", nil},
+		{"001 212 555 0123", "91", "1", "2125550123", nil},
+		{"9876543210", "91", "91", "9876543210", nil},
+		{"7911123456", "44", "44", "7911123456", nil},
+		{"2125550123", "1", "1", "2125550123", nil},
+		{"447911123456", "91", "44", "7911123456", nil},
+		{"12125550123", "91", "1", "2125550123", nil},
+		// The explicit code decides the format the rest must have
+		{"+44 9876543210", "91", "", "", ErrBadFormat},
+		{"+1 212 155 0123", "91", "", "", ErrBadFormat},
+		{"+33 612345678", "91", "", "", ErrUnsupportedCountry},
+		{"+", "91", "", "", ErrEmptyNumber},
+	}
+	for _, tt := range tests {
+		got, err := parsePhoneNumber(tt.input, tt.defaultCountry)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("parsePhoneNumber(%q, %q) error = %v, want %v", tt.input, tt.defaultCountry, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.CountryCode != tt.wantCode || got.National != tt.wantNational {
+			t.Errorf("parsePhoneNumber(%q, %q) = %+v, %v; want +%s %s", tt.input, tt.defaultCountry, got, err, tt.wantCode, tt.wantNational)
+		}
+	}
+}
+
+func TestParsePhoneNumberBareCountryCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantCode     string
+		wantNational string
+		wantErr      error
+	}{
+		{"91 and an Indian number", "919876543210", "91", "9876543210", nil},
+		{"44 and a UK number", "447911123456", "44", "7911123456", nil},
+		{"1 and a US number", "12125550123", "1", "2125550123", nil},
+		{"trunk prefix", "09876543210", "91", "9876543210", nil},
+		// A leading 1 on an Indian number fits +1, so it is not stripped
+		// down to the Indian number
+		{"1 and an Indian number", "19876543210", "1", "9876543210", nil},
+		// 91 followed by something that is no Indian mobile is not
+		// trimmed to its last 10 digits
+		{"91 and a bad national number", "915876543210", "", "", ErrBadLength},
+		{"code fitting no country", "339876543210", "", "", ErrBadLength},
+		{"one digit too many", "98765432101", "", "", ErrBadLength},
+		{"trunk prefix and a short number", "0987654321", "", "", ErrBadFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePhoneNumber(tt.input, "91")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parsePhoneNumber(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.CountryCode != tt.wantCode || got.National != tt.wantNational {
+				t.Errorf("parsePhoneNumber(%q) = %+v, %v; want +%s %s", tt.input, got, err, tt.wantCode, tt.wantNational)
+			}
+		})
+	}
+}
+
+// Sizes of the columns a cleaned number and the input it came from are
+// stored in: mobile is VARCHAR(10) and raw_mobile VARCHAR(64)
+const (
+	mobileColumnSize    = 10
+	rawMobileColumnSize = 64
+)
+
+// FuzzCleanPhoneNumber checks that no input panics and that whatever is
+// accepted fits the database: the cleaned number is digits only and fits
+// the mobile column, and the input itself, saved as raw_mobile, fits that
+// column too.
+func FuzzCleanPhoneNumber(f *testing.F) {
+	for _, seed := range []string{
+		"9876543210", "+91 98765 43210", "0091-9876543210", "09876543210",
+		"919876543210", "19876543210", "+44 7911 123456", "(212) 555-0123",
+		"", "+", "00", "0", "98765abcde", "\x00", "+91" + strings.Repeat(" ", 70) + "9876543210",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		got, err := cleanPhoneNumber(input)
+		if err != nil {
+			if got != "" {
+				t.Errorf("cleanPhoneNumber(%q) = %q with error %v", input, got, err)
+			}
+			return
+		}
+		if len(got) == 0 || len(got) > mobileColumnSize {
+			t.Errorf("cleanPhoneNumber(%q) = %q, which does not fit the mobile column", input, got)
+		}
+		for _, r := range got {
+			if r < '0' || r > '9' {
+				t.Fatalf("cleanPhoneNumber(%q) = %q, which has a non-digit", input, got)
+			}
+		}
+		if utf8.RuneCountInString(input) > rawMobileColumnSize {
+			t.Errorf("cleanPhoneNumber accepted %d characters, which do not fit raw_mobile", utf8.RuneCountInString(input))
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logPII allows full mobile numbers in logs; off by default
+var logPII bool
+
+// piiHashKey keys the HMAC used by hashMobile
+var piiHashKey []byte
+
+// maskMobile redacts all but the last 4 characters, e.g. ******0007
+func maskMobile(mobile string) string {
+	if len(mobile) <= 4 {
+		return strings.Repeat("*", len(mobile))
+	}
+	return strings.Repeat("*", len(mobile)-4) + mobile[len(mobile)-4:]
+}
+
+// hashMobile returns a stable keyed hash of the number so log lines for the
+// same number can be correlated without storing it. Set PII_HASH_KEY in
+// production; without a key the hash of a 10-digit number is easy to reverse.
+func hashMobile(mobile string) string {
+	mac := hmac.New(sha256.New, piiHashKey)
+	mac.Write([]byte(mobile))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// redactMobile returns the number as it may appear in logs: in full when
+// LOG_PII is enabled, otherwise masked
+func redactMobile(mobile string) string {
+	if logPII {
+		return mobile
+	}
+	return maskMobile(mobile)
+}
+
+// mobileLogFields identifies a number in log entries
+func mobileLogFields(mobile string) logrus.Fields {
+	return logrus.Fields{
+		"mobile":      redactMobile(mobile),
+		"mobile_hash": hashMobile(mobile),
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestRedactMobile(t *testing.T) {
+	tests := []struct {
+		name   string
+		mobile string
+		logPII bool
+		want   string
+	}{
+		{"masked", "9876543210", false, "******3210"},
+		{"masked with country code", "+919876543210", false, "*********3210"},
+		{"short", "123", false, "***"},
+		{"four digits", "1234", false, "****"},
+		{"empty", "", false, ""},
+		{"LOG_PII", "9876543210", true, "9876543210"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := logPII
+			logPII = tt.logPII
+			t.Cleanup(func() { logPII = saved })
+
+			if got := redactMobile(tt.mobile); got != tt.want {
+				t.Errorf("redactMobile(%q) = %q, want %q", tt.mobile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashMobile(t *testing.T) {
+	saved := piiHashKey
+	t.Cleanup(func() { piiHashKey = saved })
+
+	piiHashKey = []byte("key-one")
+	first := hashMobile("9876543210")
+	if len(first) != 16 {
+		t.Fatalf("hashMobile returned %d characters, want 16", len(first))
+	}
+	if again := hashMobile("9876543210"); again != first {
+		t.Errorf("hashMobile is not stable: %q then %q", first, again)
+	}
+	if other := hashMobile("9876543211"); other == first {
+		t.Error("different numbers hashed alike")
+	}
+
+	piiHashKey = []byte("key-two")
+	if rekeyed := hashMobile("9876543210"); rekeyed == first {
+		t.Error("PII_HASH_KEY does not change the hash")
+	}
+}
+
+func TestMobileLogFieldsOmitFullNumber(t *testing.T) {
+	fields := mobileLogFields("9876543210")
+	for key, value := range fields {
+		if value == "9876543210" {
+			t.Errorf("log field %s carries the full number", key)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LookupRequest describes a single name lookup. Name is optional; when set
+// the provider also checks whether it belongs to the number.
+type LookupRequest struct {
+	Mobile    string
+	Name      string
+	ClientRef string
+}
+
+// LookupResult is a provider's answer; Name is empty when none was found.
+// The match fields are only set when a name to verify was sent and the
+// provider returned them.
+type LookupResult struct {
+	Name           string
+	NameMatch      *bool
+	NameMatchScore *float64
+	// Candidates lists every possible name, Name first, when the provider
+	// returned more than one
+	Candidates []string
+	// Fields holds every result field the provider returned, typed or not
+	Fields map[string]interface{}
+	// Provider names the provider that answered
+	Provider string
+	// StatusCode is the provider's HTTP status
+	StatusCode int
+}
+
+// ErrBadProviderResponse marks a provider response that arrived but could
+// not be parsed
+var ErrBadProviderResponse = errors.New("malformed provider response")
+
+// ErrProviderStatus marks a provider response with a non-2xx HTTP status
+var ErrProviderStatus = errors.New("provider returned an error status")
+
+// ProviderError is a failed provider call, carrying what is known about it
+// for the response log. StatusCode is 0 when no HTTP response arrived.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	// Body is the start of a response that could not be parsed, kept so
+	// the response log shows what the provider actually sent
+	Body string
+	Err  error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Field returns a raw provider result field
+func (r *LookupResult) Field(key string) (interface{}, bool) {
+	value, ok := r.Fields[key]
+	return value, ok
+}
+
+// StringField returns a provider result field if it is a string
+func (r *LookupResult) StringField(key string) (string, bool) {
+	value, ok := r.Fields[key].(string)
+	return value, ok
+}
+
+// Confidence returns the provider's "confidence" field, its confidence in
+// the linked name, if it sent one. NameMatchScore is no stand-in: it rates
+// the caller's name to verify, not the linked name.
+func (r *LookupResult) Confidence() (float64, bool) {
+	value, ok := r.Fields["confidence"].(float64)
+	return value, ok
+}
+
+// candidateNames merges the primary name with the provider's candidate
+// list into one list with the primary first and without blanks or
+// duplicates. It returns nil unless there is more than one name.
+func candidateNames(primary string, candidates []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range append([]string{primary}, candidates...) {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) < 2 {
+		return nil
+	}
+	return names
+}
+
+var clientRefPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// deriveClientRef builds a reproducible client reference for a normalized
+// mobile on a given UTC day:
+//
+//	REF_<YYYYMMDD>_<first 12 hex chars of sha256("<mobile>|<YYYYMMDD>")>
+//
+// Retries of the same number on the same day share a reference, while the
+// hash keeps the number itself out of the reference sent upstream.
+func deriveClientRef(mobile string, now time.Time) string {
+	day := now.UTC().Format("20060102")
+	sum := sha256.Sum256([]byte(mobile + "|" + day))
+	return "REF_" + day + "_" + hex.EncodeToString(sum[:])[:12]
+}
+
+// validClientRef checks a caller-supplied client reference
+func validClientRef(ref string) bool {
+	return clientRefPattern.MatchString(ref)
+}
+
+// NameLookupProvider resolves the name linked to a mobile number
+type NameLookupProvider interface {
+	Lookup(ctx context.Context, req LookupRequest) (*LookupResult, error)
+}
+
+// namedProvider pairs a provider with the name used in logs
+type namedProvider struct {
+	name     string
+	provider NameLookupProvider
+}
+
+// FailoverProvider tries each provider in order until one succeeds
+type FailoverProvider struct {
+	providers []namedProvider
+}
+
+// NewFailoverProvider creates an empty failover chain
+func NewFailoverProvider() *FailoverProvider {
+	return &FailoverProvider{}
+}
+
+// Add appends a provider to the end of the chain
+func (f *FailoverProvider) Add(name string, provider NameLookupProvider) {
+	f.providers = append(f.providers, namedProvider{name: name, provider: provider})
+}
+
+// Lookup implements NameLookupProvider by trying each provider in order
+func (f *FailoverProvider) Lookup(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+	if len(f.providers) == 0 {
+		return nil, fmt.Errorf("no lookup providers configured")
+	}
+
+	var failures []string
+	var lastErr error
+	last := &ProviderError{}
+	for _, p := range f.providers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result, err := p.provider.Lookup(ctx, req)
+		if err == nil && !errorStatus(result.StatusCode) {
+			if result.Provider == "" {
+				result.Provider = p.name
+			}
+			return result, nil
+		}
+		// An error status is a failure even if the provider handed back a
+		// result, so an outage doesn't pass for "no name found"
+		if err == nil {
+			err = &ProviderError{
+				Provider:   result.Provider,
+				StatusCode: result.StatusCode,
+				Err:        fmt.Errorf("%w: %d", ErrProviderStatus, result.StatusCode),
+			}
+		}
+
+		// The call limit is shared by every provider and the wait only
+		// ends at the deadline, so there is no point trying the next one
+		if errors.Is(err, errProviderBusy) {
+			return nil, err
+		}
+
+		logger.WithError(err).WithField("provider", p.name).Warn("Lookup provider failed, trying next")
+		failures = append(failures, fmt.Sprintf("%s: %v", p.name, err))
+
+		lastErr, last = err, &ProviderError{Provider: p.name}
+		if perr, ok := err.(*ProviderError); ok {
+			last.StatusCode, last.Body = perr.StatusCode, perr.Body
+		}
+	}
+
+	// Like the status, the error kind reported is the last provider's
+	last.Err = fmt.Errorf("all lookup providers failed: %s", strings.Join(failures, "; "))
+	for _, kind := range []error{ErrBadProviderResponse, ErrProviderStatus} {
+		if errors.Is(lastErr, kind) {
+			last.Err = fmt.Errorf("%w: %v", kind, last.Err)
+			break
+		}
+	}
+	return nil, last
+}
+
+// errorStatus reports whether an HTTP status from a provider is an error.
+// 0 means the provider has no HTTP status to report.
+func errorStatus(status int) bool {
+	return status != 0 && (status < 200 || status > 299)
+}
+
+// newProvider builds the lookup provider from the configured list, wrapping
+// several in a FailoverProvider tried in order. breakers holds the circuit
+// breaker for each provider name, if any; calls, if set, caps concurrent
+// calls across all of them, and retries counts their attempts.
+func newProvider(configs []ProviderConfig, httpClient *http.Client, breakers map[string]*CircuitBreaker, calls *CallLimiter, retries *RetryStats) NameLookupProvider {
+	chain := NewFailoverProvider()
+	for _, cfg := range configs {
+		chain.Add(cfg.Name, &DigitapClient{
+			Name:       cfg.Name,
+			BaseURL:    cfg.BaseURL,
+			AuthToken:  cfg.AuthToken,
+			HTTPClient: httpClient,
+			Breaker:    breakers[cfg.Name],
+			Calls:      calls,
+			Retries:    retries,
+
+			MaxResponseBytes: cfg.MaxResponseBytes,
+			Mock:             cfg.Mock,
+			NameJSONPath:     cfg.NameJSONPath,
+			MaxAttempts:      cfg.MaxAttempts,
+			ExtraFields:      cfg.ExtraFields,
+			RetryMalformed:   cfg.RetryMalformed,
+		})
+	}
+
+	if len(chain.providers) == 1 {
+		return chain.providers[0].provider
+	}
+	return chain
+}
+
+// errProviderBusy means a provider call could not start before the request
+// deadline because the concurrent call limit was reached
+var errProviderBusy = errors.New("too many provider calls in progress")
+
+// CallLimiter caps how many provider calls are in flight at once across
+// every user, provider and tenant. A nil *CallLimiter allows any number.
+type CallLimiter struct {
+	slots chan struct{}
+}
+
+// NewCallLimiter creates a limiter allowing max concurrent calls
+func NewCallLimiter(max int) *CallLimiter {
+	return &CallLimiter{slots: make(chan struct{}, max)}
+}
+
+// Acquire waits for a free slot until ctx is done, returning
+// errProviderBusy if none frees up in time. Each successful Acquire must be
+// paired with a Release.
+func (l *CallLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", errProviderBusy, ctx.Err())
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *CallLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// InFlight returns the number of calls holding a slot
+func (l *CallLimiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
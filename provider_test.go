@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallLimiter(t *testing.T) {
+	limiter := NewCallLimiter(2)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Acquire(ctx); err != nil {
+			t.Fatalf("Acquire %d: %v", i+1, err)
+		}
+	}
+	if got := limiter.InFlight(); got != 2 {
+		t.Errorf("InFlight = %d, want 2", got)
+	}
+
+	full, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(full); !errors.Is(err, errProviderBusy) {
+		t.Errorf("Acquire past the limit = %v, want errProviderBusy", err)
+	}
+
+	limiter.Release()
+	if err := limiter.Acquire(ctx); err != nil {
+		t.Errorf("Acquire after Release: %v", err)
+	}
+
+	var unlimited *CallLimiter
+	if err := unlimited.Acquire(full); err != nil || unlimited.InFlight() != 0 {
+		t.Errorf("nil limiter: Acquire = %v, InFlight = %d; want no limit", err, unlimited.InFlight())
+	}
+	unlimited.Release()
+}
+
+func TestCallLimiterSharedAcrossClients(t *testing.T) {
+	const max = 3
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		io.WriteString(w, `{"result": {"mobile_linked_name": "Ravi Kumar"}}`)
+	}))
+	defer server.Close()
+
+	// Two providers sharing one MAX_CONCURRENT_PROVIDER_CALLS budget
+	calls := NewCallLimiter(max)
+	clients := []*DigitapClient{NewDigitapClient(server.URL, "a"), NewDigitapClient(server.URL, "b")}
+	for _, client := range clients {
+		client.Calls = calls
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(client *DigitapClient) {
+			defer wg.Done()
+			if _, err := client.Lookup(context.Background(), LookupRequest{Mobile: "9876543210"}); err != nil {
+				t.Errorf("Lookup: %v", err)
+			}
+		}(clients[i%2])
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > max {
+		t.Errorf("%d provider calls in flight, want at most %d", got, max)
+	}
+	if got := calls.InFlight(); got != 0 {
+		t.Errorf("%d slots still held after every call finished", got)
+	}
+}
+
+// providerFunc adapts a function to NameLookupProvider
+type providerFunc func(ctx context.Context, req LookupRequest) (*LookupResult, error)
+
+func (f providerFunc) Lookup(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+	return f(ctx, req)
+}
+
+func TestFailoverProvider(t *testing.T) {
+	answers := func(name string) providerFunc {
+		return func(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+			return &LookupResult{Name: name}, nil
+		}
+	}
+	fails := func(err error) providerFunc {
+		return func(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+			return nil, err
+		}
+	}
+	answersStatus := func(status int) providerFunc {
+		return func(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+			return &LookupResult{StatusCode: status}, nil
+		}
+	}
+	down := errors.New("connection refused")
+	malformed := &ProviderError{StatusCode: http.StatusOK, Body: "<html>", Err: ErrBadProviderResponse}
+
+	tests := []struct {
+		name         string
+		chain        []NameLookupProvider
+		wantName     string
+		wantProvider string
+		wantErr      bool
+		wantIs       error // when set, the error must match it
+		wantStatus   int
+	}{
+		{name: "first answers", chain: []NameLookupProvider{answers("Ravi"), answers("Asha")}, wantName: "Ravi", wantProvider: "p1"},
+		{name: "fails over", chain: []NameLookupProvider{fails(down), answers("Asha")}, wantName: "Asha", wantProvider: "p2"},
+		{name: "no name is an answer", chain: []NameLookupProvider{answers(""), answers("Asha")}, wantName: "", wantProvider: "p1"},
+		{name: "error status fails over", chain: []NameLookupProvider{answersStatus(http.StatusServiceUnavailable), answers("Asha")}, wantName: "Asha", wantProvider: "p2"},
+		{name: "all answer error statuses", chain: []NameLookupProvider{fails(down), answersStatus(http.StatusInternalServerError)}, wantErr: true, wantIs: ErrProviderStatus, wantStatus: http.StatusInternalServerError},
+		{name: "all fail", chain: []NameLookupProvider{fails(down), fails(down)}, wantErr: true},
+		{name: "last failure decides the kind", chain: []NameLookupProvider{fails(down), fails(malformed)}, wantErr: true, wantIs: ErrBadProviderResponse, wantStatus: http.StatusOK},
+		{name: "busy stops the chain", chain: []NameLookupProvider{fails(errProviderBusy), answers("Asha")}, wantErr: true, wantIs: errProviderBusy},
+		{name: "empty chain", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewFailoverProvider()
+			for i, p := range tt.chain {
+				chain.Add(fmt.Sprintf("p%d", i+1), p)
+			}
+
+			result, err := chain.Lookup(context.Background(), LookupRequest{Mobile: "9876543210"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Lookup = %+v, want an error", result)
+				}
+				if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+					t.Errorf("Lookup error = %v, want %v", err, tt.wantIs)
+				}
+				var perr *ProviderError
+				if errors.As(err, &perr) && perr.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", perr.StatusCode, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil || result.Name != tt.wantName || result.Provider != tt.wantProvider {
+				t.Errorf("Lookup = %+v, %v; want %q from %s", result, err, tt.wantName, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestFailoverProviderPrimaryErrorStatus(t *testing.T) {
+	var primaryCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `{"result": {"mobile_linked_name": ""}}`)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"result": {"mobile_linked_name": "Asha Rao"}}`)
+	}))
+	defer secondary.Close()
+
+	chain := NewFailoverProvider()
+	chain.Add("primary", &DigitapClient{Name: "primary", BaseURL: primary.URL, HTTPClient: primary.Client(), MaxAttempts: 1})
+	chain.Add("secondary", &DigitapClient{Name: "secondary", BaseURL: secondary.URL, HTTPClient: secondary.Client(), MaxAttempts: 1})
+
+	result, err := chain.Lookup(context.Background(), LookupRequest{Mobile: "9876543210"})
+	if err != nil || result.Name != "Asha Rao" || result.Provider != "secondary" {
+		t.Fatalf("Lookup = %+v, %v; want Asha Rao from secondary", result, err)
+	}
+	if primaryCalls != 1 {
+		t.Errorf("called the primary %d times, want 1", primaryCalls)
+	}
+}
+
+func TestFailoverProviderStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var second bool
+	chain := NewFailoverProvider()
+	chain.Add("p1", providerFunc(func(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+		cancel()
+		return nil, ctx.Err()
+	}))
+	chain.Add("p2", providerFunc(func(ctx context.Context, req LookupRequest) (*LookupResult, error) {
+		second = true
+		return &LookupResult{Name: "Asha"}, nil
+	}))
+
+	if _, err := chain.Lookup(ctx, LookupRequest{Mobile: "9876543210"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Lookup error = %v, want context.Canceled", err)
+	}
+	if second {
+		t.Error("tried the next provider after the caller gave up")
+	}
+}
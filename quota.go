@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DailyQuota caps how many numbers each IP may look up per UTC day, so a
+// batch of 20 numbers counts 20 times. Counts are kept in the database so
+// they survive restarts and are shared by every replica. It sits on top of
+// the rate limiter, which only smooths bursts.
+type DailyQuota struct {
+	store Store
+	limit int
+}
+
+// NewDailyQuota creates a quota of limit lookups per IP per UTC day
+func NewDailyQuota(store Store, limit int) *DailyQuota {
+	return &DailyQuota{store: store, limit: limit}
+}
+
+// quotaCharge counts the numbers one request looks up against its IP's
+// daily quota. dailyQuotaMiddleware puts it in the request context and the
+// lookup service charges it once for every valid number.
+type quotaCharge struct {
+	quota *DailyQuota
+	ip    string
+
+	mu       sync.Mutex
+	charged  bool
+	used     int
+	exceeded bool
+}
+
+type quotaKey struct{}
+
+// quotaChargeFromContext returns the request's quota charge, or nil when
+// no quota applies
+func quotaChargeFromContext(ctx context.Context) *quotaCharge {
+	charge, _ := ctx.Value(quotaKey{}).(*quotaCharge)
+	return charge
+}
+
+// take counts one lookup and reports whether it is within the quota. If
+// the count can't be updated the lookup is let through rather than failed.
+func (c *quotaCharge) take(ctx context.Context, now time.Time) bool {
+	used, err := c.quota.store.IncrementDailyUsage(ctx, c.ip, now)
+	if err != nil {
+		logger.WithError(err).Warn("Daily quota unavailable, allowing lookup")
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.charged = true
+	if used > c.used {
+		c.used = used
+	}
+	if used > c.quota.limit {
+		c.exceeded = true
+		return false
+	}
+	return true
+}
+
+// message explains a spent quota
+func (q *DailyQuota) message() string {
+	return fmt.Sprintf("Daily quota of %d lookups exceeded; it resets at midnight UTC", q.limit)
+}
+
+// quotaHeaderWriter adds the quota headers to a response once the handler
+// has charged its lookups
+type quotaHeaderWriter struct {
+	http.ResponseWriter
+	charge      *quotaCharge
+	wroteHeader bool
+}
+
+func (w *quotaHeaderWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.charge.setHeaders(w.Header(), time.Now().UTC())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *quotaHeaderWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// setHeaders sets X-Quota-Limit and X-Quota-Remaining, and Retry-After
+// with the next midnight UTC once the quota is spent. A request that
+// looked nothing up doesn't know the count, so it gets no headers.
+func (c *quotaCharge) setHeaders(header http.Header, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.charged {
+		return
+	}
+
+	remaining := c.quota.limit - c.used
+	if remaining < 0 {
+		remaining = 0
+	}
+	header.Set("X-Quota-Limit", strconv.Itoa(c.quota.limit))
+	header.Set("X-Quota-Remaining", strconv.Itoa(remaining))
+	if c.exceeded {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		header.Set("Retry-After", strconv.Itoa(int(midnight.Sub(now).Seconds())+1))
+	}
+}
+
+// Middleware applying the daily quota to the lookups next makes. The
+// charging is done by the lookup service, number by number, and numbers
+// past the quota fail with errCodeQuotaExceeded (429). Responses to
+// requests that looked anything up carry X-Quota-Limit and
+// X-Quota-Remaining.
+func dailyQuotaMiddleware(next http.HandlerFunc, quota *DailyQuota) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		charge := &quotaCharge{quota: quota, ip: remoteIP(r)}
+		next(&quotaHeaderWriter{ResponseWriter: w, charge: charge}, r.WithContext(context.WithValue(r.Context(), quotaKey{}, charge)))
+
+		if charge.exceeded {
+			logger.WithFields(logrus.Fields{
+				"ip":     charge.ip,
+				"used":   charge.used,
+				"status": "quota_exceeded",
+			}).Warn("Daily quota exceeded")
+		}
+	}
+}
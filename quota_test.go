@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+func TestDailyQuotaChargesEveryNumber(t *testing.T) {
+	tests := []struct {
+		name          string
+		lookups       []int // numbers looked up by each request in turn
+		wantAllowed   int   // of the last request's numbers
+		wantRemaining string
+		wantRetry     bool
+	}{
+		{name: "single lookup", lookups: []int{1}, wantAllowed: 1, wantRemaining: "4"},
+		{name: "batch within quota", lookups: []int{5}, wantAllowed: 5, wantRemaining: "0"},
+		{name: "batch past quota", lookups: []int{8}, wantAllowed: 5, wantRemaining: "0", wantRetry: true},
+		{name: "quota spent by earlier batch", lookups: []int{4, 3}, wantAllowed: 1, wantRemaining: "0", wantRetry: true},
+		{name: "request without lookups", lookups: []int{0}, wantAllowed: 0, wantRemaining: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := NewDailyQuota(db.NewMemoryStore(), 5)
+
+			var allowed int
+			var rec *httptest.ResponseRecorder
+			for _, n := range tt.lookups {
+				allowed = 0
+				handler := dailyQuotaMiddleware(func(w http.ResponseWriter, r *http.Request) {
+					charge := quotaChargeFromContext(r.Context())
+					for i := 0; i < n; i++ {
+						if charge.take(r.Context(), time.Now().UTC()) {
+							allowed++
+						}
+					}
+					w.WriteHeader(http.StatusOK)
+				}, quota)
+
+				rec = httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", nil)
+				req.RemoteAddr = "203.0.113.7:4000"
+				handler(rec, req)
+			}
+
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d lookups, want %d", allowed, tt.wantAllowed)
+			}
+			if got := rec.Header().Get("X-Quota-Remaining"); got != tt.wantRemaining {
+				t.Errorf("X-Quota-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if got := rec.Header().Get("Retry-After") != ""; got != tt.wantRetry {
+				t.Errorf("Retry-After set = %v, want %v", got, tt.wantRetry)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// tokenBucketScript implements a token bucket in Redis so every replica shares
+// the same per-IP state. It uses the Redis server clock to avoid skew between
+// replicas and returns {allowed (1 or 0), remaining tokens, retry after ms}.
+// Before Redis 5 a script may only write after calling TIME if it switches to
+// replicating its effects rather than itself; later versions always do.
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// redisPoolSize caps the connections the Redis client opens, and so how
+// many rate limit checks run against Redis at once
+const redisPoolSize = 16
+
+// redisTimeout bounds dialing, each read and write, and the wait for a free
+// pooled connection, so a slow Redis costs a request its fallback quickly
+const redisTimeout = 2 * time.Second
+
+// newRedisClient creates a client for a redis://[:password@]host:port[/db]
+// URL. A failed check is not retried: the script is not idempotent, and
+// the fallback limiter answers instead.
+func newRedisClient(rawURL string) (*redis.Client, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Redis URL: %v", err)
+	}
+	opts.PoolSize = redisPoolSize
+	opts.DialTimeout = redisTimeout
+	opts.ReadTimeout = redisTimeout
+	opts.WriteTimeout = redisTimeout
+	opts.PoolTimeout = redisTimeout
+	opts.MaxRetries = -1
+	return redis.NewClient(opts), nil
+}
+
+// RedisRateLimiter enforces a token bucket shared across replicas through Redis.
+// When Redis can't be reached it falls back to a local limiter.
+type RedisRateLimiter struct {
+	client   *redis.Client
+	rate     rate.Limit
+	burst    int
+	fallback Limiter
+}
+
+// NewRedisRateLimiter creates a Redis-backed limiter with the given fallback
+func NewRedisRateLimiter(client *redis.Client, r rate.Limit, b int, fallback Limiter) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client:   client,
+		rate:     r,
+		burst:    b,
+		fallback: fallback,
+	}
+}
+
+// Allow implements Limiter
+func (l *RedisRateLimiter) Allow(ip string) LimitResult {
+	values, err := tokenBucketScript.Run(context.Background(), l.client, []string{"ratelimit:" + ip},
+		float64(l.rate), l.burst).Int64Slice()
+	if err != nil {
+		logger.WithError(err).Warn("Redis rate limiter unavailable, falling back to in-memory limiter")
+		return l.fallback.Allow(ip)
+	}
+	if len(values) != 3 {
+		logger.WithField("reply", values).Warn("Unexpected Redis rate limiter reply, falling back to in-memory limiter")
+		return l.fallback.Allow(ip)
+	}
+
+	return LimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      l.burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"golang.org/x/time/rate"
+)
+
+// countingLimiter allows everything and counts the calls it gets
+type countingLimiter struct {
+	calls int
+}
+
+func (l *countingLimiter) Allow(key string) LimitResult {
+	l.calls++
+	return LimitResult{Allowed: true}
+}
+
+func newTestRedisLimiter(t *testing.T, addr string, fallback Limiter) *RedisRateLimiter {
+	t.Helper()
+	client, err := newRedisClient("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return NewRedisRateLimiter(client, rate.Every(time.Minute), 2, fallback)
+}
+
+func TestRedisRateLimiterSharesBuckets(t *testing.T) {
+	server := miniredis.RunT(t)
+	fallback := &countingLimiter{}
+	// Two replicas pointing at the same Redis
+	a := newTestRedisLimiter(t, server.Addr(), fallback)
+	b := newTestRedisLimiter(t, server.Addr(), fallback)
+
+	steps := []struct {
+		limiter       *RedisRateLimiter
+		ip            string
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{a, "203.0.113.7", true, 1},
+		{b, "203.0.113.7", true, 0},
+		{a, "203.0.113.7", false, 0},
+		{b, "198.51.100.2", true, 1},
+	}
+	for i, s := range steps {
+		got := s.limiter.Allow(s.ip)
+		if got.Allowed != s.wantAllowed || got.Remaining != s.wantRemaining || got.Limit != 2 {
+			t.Errorf("step %d: Allow(%s) = %+v, want allowed %v with %d remaining", i, s.ip, got, s.wantAllowed, s.wantRemaining)
+		}
+		if !got.Allowed && got.RetryAfter <= 0 {
+			t.Errorf("step %d: rejected without a RetryAfter", i)
+		}
+	}
+	if fallback.calls != 0 {
+		t.Errorf("fell back %d times with Redis up", fallback.calls)
+	}
+}
+
+func TestRedisRateLimiterFallsBack(t *testing.T) {
+	server := miniredis.RunT(t)
+	fallback := &countingLimiter{}
+	limiter := newTestRedisLimiter(t, server.Addr(), fallback)
+	server.Close()
+
+	if got := limiter.Allow("203.0.113.7"); !got.Allowed || fallback.calls != 1 {
+		t.Errorf("Allow with Redis down = %+v after %d fallback calls, want the fallback's answer", got, fallback.calls)
+	}
+}
+
+func TestNewRedisClientRejectsBadURL(t *testing.T) {
+	for _, url := range []string{"http://localhost:6379", "redis://localhost:6379/db"} {
+		if _, err := newRedisClient(url); err == nil {
+			t.Errorf("newRedisClient(%q) succeeded, want an error", url)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import "net/http"
+
+// readOnlyMessage answers lookups READ_ONLY can't serve from the cache
+const readOnlyMessage = "This number is not cached, and new lookups are not available in read-only mode."
+
+// Middleware refusing everything but GET and HEAD with 503 while READ_ONLY
+// is on, for admin routes that write to the database or call the provider
+func readOnlyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			respondWithAPIError(w, r, http.StatusServiceUnavailable, errCodeReadOnly, "Not available in read-only mode")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mobile-name-lookup/db"
+)
+
+func TestReadOnlyLookups(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "cached", query: "mobile=9876543210", wantStatus: http.StatusOK},
+		{name: "override", query: "mobile=9876501234", wantStatus: http.StatusOK},
+		{name: "miss", query: "mobile=9123456780", wantStatus: http.StatusServiceUnavailable, wantCode: errCodeReadOnly},
+		// Only the provider can verify a name, so even a cached number
+		// can't be verified
+		{name: "cached with a name to verify", query: "mobile=9876543210&name=Ravi", wantStatus: http.StatusServiceUnavailable, wantCode: errCodeReadOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := db.NewMemoryStore()
+			store.SaveMobileRecord(context.Background(), "9876543210", "Ravi Kumar", "")
+			store.SetOverride(context.Background(), "9876501234", "Asha Rao")
+			provider := &fakeProvider{names: map[string]string{"9123456780": "Someone"}}
+			service := newTestService(store, provider)
+			service.readOnly = true
+			server := newTestServer(t, service)
+
+			status, body := apiGet(t, server, "/api/v1/lookup?"+tt.query)
+			if status != tt.wantStatus || errorCode(body) != tt.wantCode {
+				t.Fatalf("got %d %q, want %d %q (body %v)", status, errorCode(body), tt.wantStatus, tt.wantCode, body)
+			}
+			if calls := provider.callCount(); calls != 0 {
+				t.Errorf("provider called %d times in read-only mode", calls)
+			}
+			if record, _ := store.GetMobileRecord(context.Background(), "9123456780"); record != nil {
+				t.Errorf("read-only mode cached %+v", record)
+			}
+		})
+	}
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	handler := readOnlyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for method, want := range map[string]int{
+		http.MethodGet:    http.StatusNoContent,
+		http.MethodHead:   http.StatusNoContent,
+		http.MethodPost:   http.StatusServiceUnavailable,
+		http.MethodPut:    http.StatusServiceUnavailable,
+		http.MethodDelete: http.StatusServiceUnavailable,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/api/v1/admin/overrides", nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", method, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+// cacheWarmupTimeout bounds the query that warms the record cache
+const cacheWarmupTimeout = time.Minute
+
+// recordCache is an in-memory LRU of cached names in front of the Store, so
+// lookups of hot numbers skip the database. Writes made through it drop the
+// entries they change; entries expire after ttl, which bounds how long a
+// change made on another replica (an import, purge or re-verification
+// there) goes unseen here. Every other Store method passes straight
+// through.
+type recordCache struct {
+	Store
+	size int
+	ttl  time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	mu sync.Mutex
+	// order holds the entries, most recently used first
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type recordCacheEntry struct {
+	record  db.MobileRecord
+	expires time.Time
+}
+
+// newRecordCache wraps store in an LRU of up to size records kept for ttl
+func newRecordCache(store Store, size int, ttl time.Duration) *recordCache {
+	return &recordCache{
+		Store:   store,
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// GetMobileRecord implements Store, reading the database only on a miss
+func (c *recordCache) GetMobileRecord(ctx context.Context, mobile string) (*db.MobileRecord, error) {
+	if record, ok := c.get(mobile); ok {
+		c.hits.Add(1)
+		return record, nil
+	}
+	c.misses.Add(1)
+
+	record, err := c.Store.GetMobileRecord(ctx, mobile)
+	if err == nil && record != nil {
+		c.put(record)
+	}
+	return record, err
+}
+
+// GetMobileRecordFromPrimary implements Store. It always reads the
+// database, since callers want a write the cache may not have seen yet.
+func (c *recordCache) GetMobileRecordFromPrimary(ctx context.Context, mobile string) (*db.MobileRecord, error) {
+	record, err := c.Store.GetMobileRecordFromPrimary(ctx, mobile)
+	if err == nil && record != nil {
+		c.put(record)
+	}
+	return record, err
+}
+
+// SaveMobileRecord implements Store. The entry is dropped rather than
+// replaced, as the database may store the name differently than given.
+func (c *recordCache) SaveMobileRecord(ctx context.Context, mobile, name, rawMobile string) error {
+	err := c.Store.SaveMobileRecord(ctx, mobile, name, rawMobile)
+	c.remove(mobile)
+	return err
+}
+
+// DeleteStaleRecords implements Store, emptying the cache after a purge
+func (c *recordCache) DeleteStaleRecords(ctx context.Context, olderThan time.Time) (int64, error) {
+	n, err := c.Store.DeleteStaleRecords(ctx, olderThan)
+	c.mu.Lock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.mu.Unlock()
+	return n, err
+}
+
+// Warm loads up to n of the most recently updated records, and never more
+// than fit, in a single query. The newest end up most recently used.
+// Numbers a lookup cached meanwhile are left as they are.
+func (c *recordCache) Warm(ctx context.Context, n int) (int, error) {
+	records, err := c.Store.ListMobileRecords(ctx, "", min(n, c.size), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	warmed := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		if _, ok := c.entries[records[i].Mobile]; ok {
+			continue
+		}
+		c.insert(records[i])
+		warmed++
+	}
+	return warmed, nil
+}
+
+// get returns a copy of the cached record for mobile, if there is a live one
+func (c *recordCache) get(mobile string) (*db.MobileRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[mobile]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*recordCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, mobile)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	record := entry.record
+	return &record, true
+}
+
+// put caches a copy of record as the most recently used
+func (c *recordCache) put(record *db.MobileRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(record)
+}
+
+// insert does put's work with c.mu held, evicting the least recently used
+// entry when the cache is full
+func (c *recordCache) insert(record *db.MobileRecord) {
+	entry := &recordCacheEntry{record: *record, expires: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[record.Mobile]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[record.Mobile] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*recordCacheEntry).record.Mobile)
+	}
+}
+
+// remove drops the entry for mobile, if any
+func (c *recordCache) remove(mobile string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[mobile]; ok {
+		c.order.Remove(element)
+		delete(c.entries, mobile)
+	}
+}
+
+// Snapshot returns the cache's counters for the stats endpoint
+func (c *recordCache) Snapshot() map[string]interface{} {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+	return map[string]interface{}{
+		"size":    c.size,
+		"entries": entries,
+		"hits":    c.hits.Load(),
+		"misses":  c.misses.Load(),
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+// readCountingStore counts the cache reads that reach the database
+type readCountingStore struct {
+	*db.MemoryStore
+	reads int
+}
+
+func (s *readCountingStore) GetMobileRecord(ctx context.Context, mobile string) (*db.MobileRecord, error) {
+	s.reads++
+	return s.MemoryStore.GetMobileRecord(ctx, mobile)
+}
+
+func newCountedCache(t *testing.T, records, size int, ttl time.Duration) (*recordCache, *readCountingStore) {
+	t.Helper()
+	store := &readCountingStore{MemoryStore: db.NewMemoryStore()}
+	for i := 0; i < records; i++ {
+		if err := store.SaveMobileRecord(context.Background(), fmt.Sprintf("98%08d", i), "Ravi Kumar", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newRecordCache(store, size, ttl), store
+}
+
+func TestRecordCache(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newCountedCache(t, 3, 2, time.Minute)
+
+	get := func(mobile string) *db.MobileRecord {
+		t.Helper()
+		record, err := cache.GetMobileRecord(ctx, mobile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	// A miss reads the database once; the repeat is served from memory
+	get("9800000000")
+	if record := get("9800000000"); record == nil || record.Name != "Ravi Kumar" || store.reads != 1 {
+		t.Fatalf("repeat read = %+v after %d database reads, want the cached record after 1", record, store.reads)
+	}
+
+	// Numbers without a name are never cached
+	get("9899999999")
+	get("9899999999")
+	if store.reads != 3 {
+		t.Errorf("%d database reads, want a miss for each unknown number", store.reads)
+	}
+
+	// A third number evicts the least recently used
+	get("9800000001")
+	get("9800000002")
+	get("9800000000")
+	if store.reads != 6 {
+		t.Errorf("%d database reads, want the evicted number read again", store.reads)
+	}
+
+	// A write drops the entry so the new name is read back
+	if err := cache.SaveMobileRecord(ctx, "9800000000", "Asha Rao", ""); err != nil {
+		t.Fatal(err)
+	}
+	if record := get("9800000000"); record.Name != "Asha Rao" {
+		t.Errorf("after a write the cache served %q, want Asha Rao", record.Name)
+	}
+
+	// A purge empties it
+	if _, err := cache.DeleteStaleRecords(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if entries := cache.Snapshot()["entries"]; entries != 0 {
+		t.Errorf("%v entries after a purge, want 0", entries)
+	}
+}
+
+func TestRecordCacheExpires(t *testing.T) {
+	cache, store := newCountedCache(t, 1, 10, time.Millisecond)
+	cache.GetMobileRecord(context.Background(), "9800000000")
+	time.Sleep(5 * time.Millisecond)
+	cache.GetMobileRecord(context.Background(), "9800000000")
+	if store.reads != 2 {
+		t.Errorf("%d database reads, want an expired entry read again", store.reads)
+	}
+}
+
+func TestRecordCacheWarm(t *testing.T) {
+	tests := []struct {
+		name    string
+		records int
+		size    int
+		n       int
+		want    int
+	}{
+		{name: "fewer records than asked", records: 3, size: 10, n: 5, want: 3},
+		{name: "more records than asked", records: 10, size: 10, n: 4, want: 4},
+		{name: "bounded by the cache size", records: 10, size: 3, n: 8, want: 3},
+		{name: "empty database", records: 0, size: 10, n: 5, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, store := newCountedCache(t, tt.records, tt.size, time.Minute)
+			got, err := cache.Warm(context.Background(), tt.n)
+			if err != nil || got != tt.want {
+				t.Fatalf("Warm = %d, %v; want %d", got, err, tt.want)
+			}
+
+			// The most recently updated numbers are the ones warmed
+			for i := tt.records - 1; i >= tt.records-tt.want; i-- {
+				cache.GetMobileRecord(context.Background(), fmt.Sprintf("98%08d", i))
+			}
+			if store.reads != 0 {
+				t.Errorf("%d database reads for warmed numbers, want 0", store.reads)
+			}
+		})
+	}
+}
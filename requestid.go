@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type requestIDKey struct{}
+
+// newRequestID generates a random UUIDv4 string
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// requestIDFromContext returns the request ID stored by requestIDMiddleware
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// maxIncomingRequestIDLength bounds request IDs adopted from a gateway
+const maxIncomingRequestIDLength = 200
+
+// validRequestIDHeader reports whether name can be used as a header name:
+// letters, digits and hyphens only
+func validRequestIDHeader(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// incomingRequestID returns the ID a gateway put in header, or "" if it is
+// missing or unfit for logs: too long, or containing spaces or control
+// characters
+func incomingRequestID(r *http.Request, header string) string {
+	if header == "" {
+		return ""
+	}
+	id := strings.TrimSpace(r.Header.Get(header))
+	if len(id) > maxIncomingRequestIDLength {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return ""
+		}
+	}
+	return id
+}
+
+// Middleware assigning every request an ID, exposed in the X-Request-ID
+// header. When sourceHeader is set and the request carries a usable value
+// in it, that ID is adopted instead of generating one, so logs line up with
+// the gateway that assigned it.
+func requestIDMiddleware(next http.Handler, sourceHeader string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := incomingRequestID(r, sourceHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		sourceHeader string
+		incoming     string
+		wantAdopted  bool
+	}{
+		{name: "generated", sourceHeader: "", incoming: "gw-123"},
+		{name: "adopted", sourceHeader: "X-Amzn-Trace-Id", incoming: "Root=1-5f84c7a9-3a4f", wantAdopted: true},
+		{name: "adopted, trimmed", sourceHeader: "X-Amzn-Trace-Id", incoming: "  gw-123 ", wantAdopted: true},
+		{name: "missing", sourceHeader: "X-Amzn-Trace-Id"},
+		{name: "too long", sourceHeader: "X-Amzn-Trace-Id", incoming: strings.Repeat("a", maxIncomingRequestIDLength+1)},
+		{name: "longest allowed", sourceHeader: "X-Amzn-Trace-Id", incoming: strings.Repeat("a", maxIncomingRequestIDLength), wantAdopted: true},
+		{name: "inner space", sourceHeader: "X-Amzn-Trace-Id", incoming: "gw 123"},
+		{name: "control character", sourceHeader: "X-Amzn-Trace-Id", incoming: "gw-123\x1b[31m"},
+		{name: "non-ASCII", sourceHeader: "X-Amzn-Trace-Id", incoming: "gw-12é"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = requestIDFromContext(r.Context())
+			}), tt.sourceHeader)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/lookup", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Amzn-Trace-Id", tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get("X-Request-ID")
+			if id != fromContext {
+				t.Errorf("header ID %q differs from the context's %q", id, fromContext)
+			}
+			if tt.wantAdopted {
+				if id != strings.TrimSpace(tt.incoming) {
+					t.Errorf("ID = %q, want the incoming %q", id, tt.incoming)
+				}
+			} else if !uuidV4.MatchString(id) {
+				t.Errorf("ID = %q, want a generated UUIDv4", id)
+			}
+		})
+	}
+}
+
+func TestValidRequestIDHeader(t *testing.T) {
+	for name, want := range map[string]bool{
+		"X-Request-ID":     true,
+		"X-Amzn-Trace-Id":  true,
+		"traceparent":      true,
+		"":                 false,
+		"X Request ID":     false,
+		"X-Request-ID:":    false,
+		"X_Request_ID":     false,
+		"X-Request-ID\r\n": false,
+	} {
+		if got := validRequestIDHeader(name); got != want {
+			t.Errorf("validRequestIDHeader(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+// responseLogWriter saves api_response_logs rows off the request path. Saved
+// entries are queued and written by a single goroutine in multi-row inserts
+// of up to batchSize rows, at least every interval. When the queue is full
+// entries are dropped and counted rather than slowing lookups down.
+type responseLogWriter struct {
+	store     Store
+	batchSize int
+	interval  time.Duration
+
+	entries  chan *db.APIResponseLog
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	written atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// newResponseLogWriter starts a writer queueing up to bufferSize entries
+func newResponseLogWriter(store Store, bufferSize, batchSize int, interval time.Duration) *responseLogWriter {
+	w := &responseLogWriter{
+		store:     store,
+		batchSize: batchSize,
+		interval:  interval,
+		entries:   make(chan *db.APIResponseLog, bufferSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Save queues a copy of entry without waiting for the database. Entries
+// saved once Close has been called are dropped.
+func (w *responseLogWriter) Save(entry *db.APIResponseLog) {
+	select {
+	case <-w.stop:
+		w.dropped.Add(1)
+		return
+	default:
+	}
+
+	copied := *entry
+	select {
+	case w.entries <- &copied:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *responseLogWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]*db.APIResponseLog, 0, w.batchSize)
+	for {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+			if len(batch) >= w.batchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.stop:
+			// Write whatever is still queued before exiting
+			for {
+				select {
+				case entry := <-w.entries:
+					batch = append(batch, entry)
+					if len(batch) >= w.batchSize {
+						batch = w.flush(batch)
+					}
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch and returns it emptied for reuse. A failed insert is
+// logged and its entries counted as failed; they are not retried.
+func (w *responseLogWriter) flush(batch []*db.APIResponseLog) []*db.APIResponseLog {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := w.store.SaveAPIResponseLogs(context.Background(), batch); err != nil {
+		logger.WithError(err).WithField("entries", len(batch)).Error("Failed to save API response logs")
+		w.failed.Add(int64(len(batch)))
+	} else {
+		w.written.Add(int64(len(batch)))
+	}
+	return batch[:0]
+}
+
+// Close writes the queued entries and stops the writer, giving up when ctx
+// is done
+func (w *responseLogWriter) Close(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Snapshot reports the writer's counters. A rising dropped count means
+// RESPONSE_LOG_BUFFER is too small for the load or the database can't
+// keep up.
+func (w *responseLogWriter) Snapshot() map[string]interface{} {
+	return map[string]interface{}{
+		"queued":  len(w.entries),
+		"written": w.written.Load(),
+		"dropped": w.dropped.Load(),
+		"failed":  w.failed.Load(),
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+// batchRecordingStore records the size of each multi-row insert and fails
+// them with err when set. Inserts wait while block is open.
+type batchRecordingStore struct {
+	*db.MemoryStore
+	err   error
+	block chan struct{}
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (s *batchRecordingStore) SaveAPIResponseLogs(ctx context.Context, entries []*db.APIResponseLog) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	s.batches = append(s.batches, len(entries))
+	s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return s.MemoryStore.SaveAPIResponseLogs(ctx, entries)
+}
+
+func (s *batchRecordingStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.batches...)
+}
+
+func TestResponseLogWriterBatches(t *testing.T) {
+	store := &batchRecordingStore{MemoryStore: db.NewMemoryStore()}
+	// An interval long enough that only full batches and Close flush
+	w := newResponseLogWriter(store, 100, 4, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		w.Save(&db.APIResponseLog{Mobile: "9876543210", Status: "success"})
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := store.batchSizes()
+	if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 4 || sizes[2] != 2 {
+		t.Errorf("batch sizes = %v, want [4 4 2]", sizes)
+	}
+	if got := len(store.APIResponseLogs()); got != 10 {
+		t.Errorf("saved %d entries, want 10", got)
+	}
+	if snapshot := w.Snapshot(); snapshot["written"] != int64(10) || snapshot["dropped"] != int64(0) {
+		t.Errorf("snapshot = %v", snapshot)
+	}
+}
+
+func TestResponseLogWriterFlushesOnInterval(t *testing.T) {
+	store := &batchRecordingStore{MemoryStore: db.NewMemoryStore()}
+	w := newResponseLogWriter(store, 100, 50, 10*time.Millisecond)
+	defer w.Close(context.Background())
+
+	w.Save(&db.APIResponseLog{Mobile: "9876543210", Status: "success"})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(store.APIResponseLogs()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("a partial batch was never flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResponseLogWriterCopiesEntries(t *testing.T) {
+	store := &batchRecordingStore{MemoryStore: db.NewMemoryStore()}
+	w := newResponseLogWriter(store, 10, 10, time.Hour)
+
+	entry := &db.APIResponseLog{Mobile: "9876543210", Status: "success"}
+	w.Save(entry)
+	entry.Status = "changed"
+	w.Close(context.Background())
+
+	if logs := store.APIResponseLogs(); len(logs) != 1 || logs[0].Status != "success" {
+		t.Errorf("saved %+v, want the entry as it was when saved", logs)
+	}
+}
+
+func TestResponseLogWriterDropsWhenFull(t *testing.T) {
+	store := &batchRecordingStore{MemoryStore: db.NewMemoryStore(), block: make(chan struct{})}
+	w := newResponseLogWriter(store, 2, 1, time.Hour)
+
+	// The first entry is taken off the queue and held up in the insert;
+	// two more fill the queue and the rest are dropped
+	w.Save(&db.APIResponseLog{Mobile: "9876543210"})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(w.entries) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the writer never took the first entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		w.Save(&db.APIResponseLog{Mobile: "9876543210"})
+	}
+	if got := w.Snapshot()["dropped"]; got != int64(3) {
+		t.Errorf("dropped = %v, want 3", got)
+	}
+
+	close(store.block)
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Snapshot()["written"]; got != int64(3) {
+		t.Errorf("written = %v, want 3", got)
+	}
+
+	// Saves after Close are dropped too
+	w.Save(&db.APIResponseLog{Mobile: "9876543210"})
+	if got := w.Snapshot()["dropped"]; got != int64(4) {
+		t.Errorf("dropped after Close = %v, want 4", got)
+	}
+}
+
+func TestResponseLogWriterCountsFailures(t *testing.T) {
+	store := &batchRecordingStore{MemoryStore: db.NewMemoryStore(), err: errors.New("connection refused")}
+	w := newResponseLogWriter(store, 10, 10, time.Hour)
+	for i := 0; i < 3; i++ {
+		w.Save(&db.APIResponseLog{Mobile: "9876543210"})
+	}
+	w.Close(context.Background())
+
+	if snapshot := w.Snapshot(); snapshot["failed"] != int64(3) || snapshot["written"] != int64(0) {
+		t.Errorf("snapshot = %v, want 3 failed and none written", snapshot)
+	}
+}
+
+func TestResponseLogWriterCloseGivesUp(t *testing.T) {
+	store := &batchRecordingStore{MemoryStore: db.NewMemoryStore(), block: make(chan struct{})}
+	defer close(store.block)
+	w := newResponseLogWriter(store, 10, 1, time.Hour)
+	w.Save(&db.APIResponseLog{Mobile: "9876543210"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close = %v, want context.DeadlineExceeded while the insert hangs", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"mobile-name-lookup/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reverifyHandler serves POST /api/v1/records/{mobile}/reverify for admins.
+// It asks the provider for the number again, bypassing the cache, and
+// compares the answer with the cached name to measure how often cached
+// names go stale. With ?update=true a changed name replaces the cached one;
+// a number the provider no longer knows keeps its cached name either way.
+// Every comparison is kept in api_response_logs with status "reverify".
+func reverifyHandler(service *lookupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/v1/records/")
+		raw, ok := strings.CutSuffix(rest, "/reverify")
+		if !ok || raw == "" || strings.Contains(raw, "/") {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Not found")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		mobile, err := cleanPhoneNumber(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, mobileErrorCode(err), fmt.Sprintf("Invalid mobile number: %v", err))
+			return
+		}
+		update := r.URL.Query().Get("update") == "true"
+
+		if service.dryRunMode {
+			writeJSONError(w, http.StatusConflict, errCodeConflict, "Reverifying calls the provider, which DRY_RUN disables")
+			return
+		}
+
+		record, err := service.database.GetMobileRecordFromPrimary(r.Context(), mobile)
+		if err != nil {
+			logger.WithError(err).Error("Failed to query database")
+			writeDatabaseError(w, err)
+			return
+		}
+		if record == nil {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "No cached name for this number")
+			return
+		}
+
+		start := time.Now()
+		result, err := service.providerFor(r.Context()).Lookup(r.Context(), LookupRequest{
+			Mobile:    mobile,
+			ClientRef: deriveClientRef(mobile, start),
+		})
+		entry := &db.APIResponseLog{Mobile: mobile, LatencyMs: time.Since(start).Milliseconds()}
+		outcome := &lookupOutcome{Mobile: mobile}
+		if err != nil {
+			logger.WithError(err).WithFields(mobileLogFields(mobile)).Error("Reverify lookup failed")
+
+			entry.Status = "error"
+			var perr *ProviderError
+			if errors.As(err, &perr) {
+				entry.Provider, entry.StatusCode = perr.Provider, perr.StatusCode
+			}
+			service.saveResponseLog(outcome, entry, map[string]interface{}{"error": err.Error()})
+
+			switch {
+			case errors.Is(err, errProviderBusy):
+				writeJSONError(w, http.StatusServiceUnavailable, errCodeProviderBusy, "Too many lookups in progress. Please try again.")
+			case errors.Is(err, context.DeadlineExceeded):
+				writeJSONError(w, http.StatusGatewayTimeout, errCodeTimeout, "Lookup timed out. Please try again.")
+			default:
+				writeJSONError(w, http.StatusBadGateway, errCodeUpstream, "Service temporarily unavailable. Please try again.")
+			}
+			return
+		}
+
+		fresh := service.acceptedName(mobile, result)
+		changed := fresh != record.Name
+		updated := false
+		if changed && update && fresh != "" {
+			// Keep the raw input of the original save rather than blanking it
+			if err := service.database.SaveMobileRecord(context.WithoutCancel(r.Context()), mobile, fresh, record.RawMobile); err != nil {
+				logger.WithError(err).Error("Failed to update reverified record")
+				writeDatabaseError(w, err)
+				return
+			}
+			updated = true
+			auditAdminAction(service.database, r, auditRecordReverified, mobile, "")
+		}
+
+		entry.Status, entry.Provider, entry.StatusCode = "reverify", result.Provider, result.StatusCode
+		service.saveResponseLog(outcome, entry, map[string]interface{}{
+			"cached_name":      record.Name,
+			"cached_at":        record.UpdatedAt,
+			"fresh_name":       fresh,
+			"provider_name":    result.Name,
+			"changed":          changed,
+			"updated":          updated,
+			"provider_results": result.Fields,
+		})
+
+		logger.WithFields(mobileLogFields(mobile)).WithFields(logrus.Fields{
+			"changed": changed,
+			"updated": updated,
+		}).Info("Reverified cached name")
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"mobile":      mobile,
+			"cached_name": record.Name,
+			"cached_at":   record.UpdatedAt,
+			"fresh_name":  fresh,
+			"provider":    result.Provider,
+			"changed":     changed,
+			"updated":     updated,
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LookupStats counts how lookups were served. Counters live in memory and
+// reset on restart.
+type LookupStats struct {
+	startedAt time.Time
+	total     atomic.Int64
+	overrides atomic.Int64
+	dbHits    atomic.Int64
+	apiHits   atomic.Int64
+	notFound  atomic.Int64
+	// deduped counts repeats answered from the dedupe window
+	deduped atomic.Int64
+	// readOnlyMisses counts lookups turned away in read-only mode
+	readOnlyMisses atomic.Int64
+
+	// breakers are the provider circuit breakers by name, reported
+	// alongside the counters
+	breakers map[string]*CircuitBreaker
+	// backfill, if set, reports the progress of the backfill job
+	backfill *backfillJob
+	// dbPool, if set, reads the database connection pool statistics
+	dbPool func() sql.DBStats
+	// lockPool, if set, reads the statistics of the lookup lock pool
+	lockPool func() sql.DBStats
+	// retries counts provider call attempts
+	retries *RetryStats
+	// latency is the end-to-end request latency by the source that
+	// served the lookup
+	latency *LatencyStats
+	// responseLogs, if set, reports the background response log writer
+	responseLogs *responseLogWriter
+	// recordCache, if set, reports the in-memory record cache
+	recordCache *recordCache
+}
+
+// NewLookupStats creates zeroed counters
+func NewLookupStats() *LookupStats {
+	return &LookupStats{startedAt: time.Now(), latency: NewLatencyStats()}
+}
+
+// Snapshot returns the counters and the cache hit ratio, the share of
+// cache-eligible lookups served from the DB instead of the paid API
+func (s *LookupStats) Snapshot() map[string]interface{} {
+	dbHits, apiHits := s.dbHits.Load(), s.apiHits.Load()
+
+	var hitRatio float64
+	if dbHits+apiHits > 0 {
+		hitRatio = float64(dbHits) / float64(dbHits+apiHits)
+	}
+
+	snapshot := map[string]interface{}{
+		"since":            s.startedAt.UTC(),
+		"total_lookups":    s.total.Load(),
+		"overrides":        s.overrides.Load(),
+		"db_hits":          dbHits,
+		"api_hits":         apiHits,
+		"not_found":        s.notFound.Load(),
+		"deduped":          s.deduped.Load(),
+		"read_only_misses": s.readOnlyMisses.Load(),
+		"hit_ratio":        hitRatio,
+	}
+
+	if len(s.breakers) > 0 {
+		breakers := make(map[string]interface{}, len(s.breakers))
+		for name, breaker := range s.breakers {
+			breakers[name] = breaker.Snapshot()
+		}
+		snapshot["circuit_breakers"] = breakers
+	}
+	if s.backfill != nil {
+		snapshot["backfill"] = s.backfill.Snapshot()
+	}
+	if s.dbPool != nil {
+		snapshot["db_pool"] = dbPoolSnapshot(s.dbPool())
+	}
+	if s.lockPool != nil {
+		snapshot["db_lock_pool"] = dbPoolSnapshot(s.lockPool())
+	}
+	if s.retries != nil {
+		snapshot["provider_retries"] = s.retries.Snapshot()
+	}
+	if s.responseLogs != nil {
+		snapshot["response_log_writer"] = s.responseLogs.Snapshot()
+	}
+	if s.recordCache != nil {
+		snapshot["record_cache"] = s.recordCache.Snapshot()
+	}
+	if s.latency != nil {
+		snapshot["latency_by_source"] = s.latency.Snapshot()
+	}
+	return snapshot
+}
+
+// latencyBucketsMs are the upper bounds of the latency histogram buckets.
+// Cache hits should land in the first few and provider calls in the last
+// few; a single blended histogram would hide both.
+var latencyBucketsMs = []float64{0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// latencyHistogram counts observations per bucket, the last bucket
+// catching everything above latencyBucketsMs
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	sumMs  float64
+}
+
+// LatencyStats is a latency histogram per source label. A nil
+// *LatencyStats records nothing.
+type LatencyStats struct {
+	mu       sync.Mutex
+	bySource map[string]*latencyHistogram
+}
+
+// NewLatencyStats creates an empty histogram
+func NewLatencyStats() *LatencyStats {
+	return &LatencyStats{bySource: make(map[string]*latencyHistogram)}
+}
+
+// Observe records one request served by source that took d
+func (l *LatencyStats) Observe(source string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	ms := float64(d) / float64(time.Millisecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.bySource[source]
+	if !ok {
+		h = &latencyHistogram{counts: make([]int64, len(latencyBucketsMs)+1)}
+		l.bySource[source] = h
+	}
+	i := sort.SearchFloat64s(latencyBucketsMs, ms)
+	h.counts[i]++
+	h.count++
+	h.sumMs += ms
+}
+
+// Snapshot reports each source's count, total and buckets. Buckets are
+// cumulative and keyed by their upper bound in milliseconds, like a
+// Prometheus histogram's le label.
+func (l *LatencyStats) Snapshot() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snapshot := make(map[string]interface{}, len(l.bySource))
+	for source, h := range l.bySource {
+		buckets := make(map[string]int64, len(h.counts))
+		var cumulative int64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(latencyBucketsMs) {
+				le = strconv.FormatFloat(latencyBucketsMs[i], 'f', -1, 64)
+			}
+			buckets[le] = cumulative
+		}
+		snapshot[source] = map[string]interface{}{
+			"count":   h.count,
+			"sum_ms":  h.sumMs,
+			"buckets": buckets,
+		}
+	}
+	return snapshot
+}
+
+// RetryStats counts how provider calls fared across their retry attempts.
+// A nil *RetryStats records nothing.
+type RetryStats struct {
+	// retries counts attempts after the first
+	retries           atomic.Int64
+	successAfterRetry atomic.Int64
+	exhausted         atomic.Int64
+
+	mu sync.Mutex
+	// succeededOn counts successful calls by the attempt they succeeded
+	// on, starting at 1
+	succeededOn map[int]int64
+}
+
+// NewRetryStats creates zeroed counters
+func NewRetryStats() *RetryStats {
+	return &RetryStats{succeededOn: make(map[int]int64)}
+}
+
+// Retry records an attempt after the first
+func (r *RetryStats) Retry() {
+	if r == nil {
+		return
+	}
+	r.retries.Add(1)
+}
+
+// Success records a call that got a response on the given attempt
+func (r *RetryStats) Success(attempt int) {
+	if r == nil {
+		return
+	}
+	if attempt > 1 {
+		r.successAfterRetry.Add(1)
+	}
+	r.mu.Lock()
+	r.succeededOn[attempt]++
+	r.mu.Unlock()
+}
+
+// Exhausted records a call that failed on every attempt
+func (r *RetryStats) Exhausted() {
+	if r == nil {
+		return
+	}
+	r.exhausted.Add(1)
+}
+
+// Snapshot reports the counters. A rising success_after_retry or
+// exhausted count shows the provider degrading before calls start failing
+// outright.
+func (r *RetryStats) Snapshot() map[string]interface{} {
+	r.mu.Lock()
+	succeededOn := make(map[string]int64, len(r.succeededOn))
+	for attempt, count := range r.succeededOn {
+		succeededOn[strconv.Itoa(attempt)] = count
+	}
+	r.mu.Unlock()
+
+	return map[string]interface{}{
+		"retries":             r.retries.Load(),
+		"success_after_retry": r.successAfterRetry.Load(),
+		"exhausted":           r.exhausted.Load(),
+		"succeeded_on":        succeededOn,
+	}
+}
+
+// dbPoolSnapshot reports connection pool statistics. A growing wait_count
+// means requests are queuing for a connection and DB_MAX_OPEN_CONNS is too
+// low for the load.
+func dbPoolSnapshot(stats sql.DBStats) map[string]interface{} {
+	return map[string]interface{}{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	}
+}
+
+// statsHandler serves GET /api/v1/stats
+func statsHandler(stats *LookupStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, stats.Snapshot())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mobile-name-lookup/db"
+)
+
+// Store is the storage the handlers use. *db.DB implements it against
+// MySQL and db.MemoryStore in memory, for tests and local runs.
+type Store interface {
+	GetMobileRecord(ctx context.Context, mobile string) (*db.MobileRecord, error)
+	GetMobileRecordFromPrimary(ctx context.Context, mobile string) (*db.MobileRecord, error)
+	SaveMobileRecord(ctx context.Context, mobile, name, rawMobile string) error
+	FindByName(ctx context.Context, name string, prefix bool, limit, offset int) ([]*db.MobileRecord, error)
+	ListMobileRecords(ctx context.Context, search string, limit, offset int) ([]*db.MobileRecord, error)
+	SearchBySuffix(ctx context.Context, suffix string, limit int) ([]*db.MobileRecord, error)
+	DeleteStaleRecords(ctx context.Context, olderThan time.Time) (int64, error)
+	UnresolvedMobiles(ctx context.Context, after string, limit int) ([]string, error)
+
+	GetOverride(ctx context.Context, mobile string) (*db.Override, error)
+	SetOverride(ctx context.Context, mobile, name string) error
+	DeleteOverride(ctx context.Context, mobile string) (bool, error)
+
+	SaveAPIResponseLog(ctx context.Context, entry *db.APIResponseLog) error
+	SaveAPIResponseLogs(ctx context.Context, entries []*db.APIResponseLog) error
+	RecentLookups(ctx context.Context, limit int) ([]*db.APIResponseLog, error)
+
+	IncrementDailyUsage(ctx context.Context, client string, day time.Time) (int, error)
+
+	SaveAdminAudit(ctx context.Context, entry *db.AdminAuditEntry) error
+	ListAdminAudit(ctx context.Context, limit, offset int) ([]*db.AdminAuditEntry, error)
+
+	LockMobile(ctx context.Context, mobile string, timeout time.Duration) (func(), error)
+}
+
+var (
+	_ Store = (*db.DB)(nil)
+	_ Store = (*db.MemoryStore)(nil)
+)
+
+// databaseError is the lookupError for a failed Store call. Store calls
+// run under the request's context, so one cut short by REQUEST_TIMEOUT is
+// a timeout like any other rather than a database fault.
+func databaseError(err error) *lookupError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &lookupError{http.StatusGatewayTimeout, errCodeTimeout, "Request timed out. Please try again."}
+	}
+	return &lookupError{http.StatusInternalServerError, errCodeDatabase, "Database error occurred"}
+}
+
+// writeDatabaseError answers an API request whose Store call failed
+func writeDatabaseError(w http.ResponseWriter, err error) {
+	lerr := databaseError(err)
+	writeJSONError(w, lerr.Status, lerr.Code, lerr.Message)
+}
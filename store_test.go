@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"mobile-name-lookup/db"
+)
+
+// Both stores must keep satisfying Store
+var (
+	_ Store = (*db.DB)(nil)
+	_ Store = (*db.MemoryStore)(nil)
+)
+
+func TestDatabaseError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, errCodeTimeout},
+		{"wrapped deadline", fmt.Errorf("error reading record: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, errCodeTimeout},
+		{"cancelled", context.Canceled, http.StatusInternalServerError, errCodeDatabase},
+		{"other", errors.New("connection refused"), http.StatusInternalServerError, errCodeDatabase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lerr := databaseError(tt.err)
+			if lerr.Status != tt.wantStatus || lerr.Code != tt.wantCode {
+				t.Errorf("databaseError(%v) = %d %s, want %d %s", tt.err, lerr.Status, lerr.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
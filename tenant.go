@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTenantBaseURL is used for tenants configured without a base_url
+const defaultTenantBaseURL = "https://svc.digitap.ai"
+
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// TenantConfig is the Digitap sub-account used for one tenant
+type TenantConfig struct {
+	BaseURL   string `json:"base_url"`
+	AuthToken string `json:"auth_token"`
+}
+
+// parseTenants reads a JSON object mapping tenant IDs to their sub-account:
+//
+//	{"acme": {"base_url": "https://svc.digitap.ai", "auth_token": "..."}}
+func parseTenants(data []byte) (map[string]TenantConfig, error) {
+	var tenants map[string]TenantConfig
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("invalid tenant JSON: %v", err)
+	}
+
+	for id, tenant := range tenants {
+		if !tenantIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("tenant ID %q must be 1-64 letters, digits, underscores or hyphens", id)
+		}
+		if tenant.AuthToken == "" {
+			return nil, fmt.Errorf("tenant %q has no auth_token", id)
+		}
+		if tenant.BaseURL == "" {
+			tenant.BaseURL = defaultTenantBaseURL
+			tenants[id] = tenant
+		}
+	}
+	return tenants, nil
+}
+
+type tenantKey struct{}
+
+// tenantFromContext returns the tenant stored by tenantMiddleware, or ""
+// for requests served with the global provider configuration
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantResolver hands out the lookup provider for each tenant. Clients
+// are built on first use and reused, each with its own circuit breaker so
+// one sub-account's outage doesn't trip the others.
+type TenantResolver struct {
+	tenants          map[string]TenantConfig
+	httpClient       *http.Client
+	calls            *CallLimiter
+	retries          *RetryStats
+	maxResponseBytes int64
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	mu      sync.Mutex
+	clients map[string]NameLookupProvider
+}
+
+// NewTenantResolver creates a resolver for the configured tenants. A
+// breakerThreshold of 0 disables the per-tenant circuit breakers.
+func NewTenantResolver(tenants map[string]TenantConfig, httpClient *http.Client, calls *CallLimiter, retries *RetryStats, maxResponseBytes int64, breakerThreshold int, breakerCooldown time.Duration) *TenantResolver {
+	return &TenantResolver{
+		tenants:          tenants,
+		httpClient:       httpClient,
+		calls:            calls,
+		retries:          retries,
+		maxResponseBytes: maxResponseBytes,
+		breakerThreshold: breakerThreshold,
+		breakerCooldown:  breakerCooldown,
+		clients:          make(map[string]NameLookupProvider),
+	}
+}
+
+// Known reports whether tenant is configured
+func (t *TenantResolver) Known(tenant string) bool {
+	_, ok := t.tenants[tenant]
+	return ok
+}
+
+// Provider returns the client for tenant, or nil if it isn't configured
+func (t *TenantResolver) Provider(tenant string) NameLookupProvider {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if client, ok := t.clients[tenant]; ok {
+		return client
+	}
+	cfg, ok := t.tenants[tenant]
+	if !ok {
+		return nil
+	}
+
+	client := &DigitapClient{
+		Name:       "tenant:" + tenant,
+		BaseURL:    cfg.BaseURL,
+		AuthToken:  cfg.AuthToken,
+		HTTPClient: t.httpClient,
+		Calls:      t.calls,
+		Retries:    t.retries,
+
+		MaxResponseBytes: t.maxResponseBytes,
+	}
+	if t.breakerThreshold > 0 {
+		client.Breaker = NewCircuitBreaker(t.breakerThreshold, t.breakerCooldown)
+	}
+	t.clients[tenant] = client
+	return client
+}
+
+// Middleware reading the tenant from the X-Tenant header. Requests without
+// the header use the global provider configuration; an unknown tenant is
+// rejected rather than silently billed to the global account.
+func tenantMiddleware(next http.Handler, resolver *TenantResolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get("X-Tenant")
+		if tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !resolver.Known(tenant) {
+			logger.WithFields(logrus.Fields{
+				"ip":     r.RemoteAddr,
+				"path":   r.URL.Path,
+				"status": "unknown_tenant",
+			}).Warn("Rejected request for unknown tenant")
+
+			if isAPIRequest(r) {
+				respondWithAPIError(w, r, http.StatusBadRequest, errCodeUnknownTenant, "Unknown tenant")
+			} else {
+				http.Error(w, "Unknown tenant", http.StatusBadRequest)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant)))
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	webhookWorkers   = 2
+	webhookQueueSize = 100
+)
+
+// WebhookEvent is the payload posted when a new name is resolved from the API
+type WebhookEvent struct {
+	Mobile     string    `json:"mobile"`
+	Name       string    `json:"name"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+type webhookJob struct {
+	requestID string
+	event     WebhookEvent
+}
+
+// WebhookNotifier delivers events asynchronously with a small worker pool
+type WebhookNotifier struct {
+	URL        string
+	Secret     []byte
+	HTTPClient *http.Client
+	queue      chan webhookJob
+}
+
+// NewWebhookNotifier creates a notifier and starts its workers
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	n := &WebhookNotifier{
+		URL:        url,
+		Secret:     []byte(secret),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan webhookJob, webhookQueueSize),
+	}
+	for i := 0; i < webhookWorkers; i++ {
+		go n.worker()
+	}
+	return n
+}
+
+// Notify queues an event without blocking. Events are dropped when the queue
+// is full so a slow receiver can never hold up lookups. Safe on a nil notifier.
+func (n *WebhookNotifier) Notify(requestID string, event WebhookEvent) {
+	if n == nil {
+		return
+	}
+
+	select {
+	case n.queue <- webhookJob{requestID: requestID, event: event}:
+	default:
+		logger.WithField("request_id", requestID).Warn("Webhook queue full, dropping event")
+	}
+}
+
+func (n *WebhookNotifier) worker() {
+	for job := range n.queue {
+		if err := n.deliver(job); err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"request_id": job.requestID,
+				"url":        n.URL,
+			}).Error("Webhook delivery failed")
+		}
+	}
+}
+
+// sign returns the hex HMAC-SHA256 of the body using the shared secret
+func (n *WebhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, n.Secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *WebhookNotifier) deliver(job webhookJob) error {
+	body, err := json.Marshal(job.event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", job.requestID)
+	req.Header.Set("X-Signature-256", "sha256="+n.sign(body))
+
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
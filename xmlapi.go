@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// wantsXML reports whether an API client asked for XML, with ?format=xml or
+// an Accept header naming XML but not JSON. JSON stays the default.
+func wantsXML(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "xml":
+		return true
+	case "json":
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	return (strings.Contains(accept, "application/xml") || strings.Contains(accept, "text/xml")) &&
+		!strings.Contains(accept, "application/json")
+}
+
+// lookupResponseXML is the XML form of a lookup response. It carries the
+// same fields as apiJSON, with candidate names as <name> elements and extra
+// provider fields as <field name="..."> elements.
+type lookupResponseXML struct {
+	XMLName         xml.Name        `xml:"response"`
+	Status          string          `xml:"status"`
+	Source          string          `xml:"source"`
+	Message         *string         `xml:"message,omitempty"`
+	ClientRef       string          `xml:"client_ref_num,omitempty"`
+	CacheAgeSeconds *int64          `xml:"cache_age_seconds,omitempty"`
+	CacheBypassed   bool            `xml:"cache_bypassed,omitempty"`
+	Result          lookupResultXML `xml:"result"`
+}
+
+type lookupResultXML struct {
+	Mobile           string     `xml:"mobile"`
+	MobileLinkedName string     `xml:"mobile_linked_name"`
+	NameFound        *bool      `xml:"name_found,omitempty"`
+	NameMatch        *bool      `xml:"name_match,omitempty"`
+	NameMatchScore   *float64   `xml:"name_match_score,omitempty"`
+	CandidateNames   *xmlNames  `xml:"candidate_names,omitempty"`
+	Match            *bool      `xml:"match,omitempty"`
+	MatchScore       *float64   `xml:"match_score,omitempty"`
+	Fields           *xmlFields `xml:"fields,omitempty"`
+}
+
+// xmlNames and xmlFields are pointers in lookupResultXML so that absent
+// lists leave out their wrapper element too
+type xmlNames struct {
+	Names []string `xml:"name"`
+}
+
+type xmlFields struct {
+	Fields []xmlField `xml:"field"`
+}
+
+type xmlField struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// apiXML is the XML counterpart of apiJSON
+func (o *lookupOutcome) apiXML() *lookupResponseXML {
+	body := &lookupResponseXML{
+		Status:        "success",
+		Source:        o.Source,
+		CacheBypassed: o.CacheBypassed,
+		Result: lookupResultXML{
+			Mobile:           o.Mobile,
+			MobileLinkedName: o.displayName(),
+		},
+	}
+	if o.Source != sourceDryRun {
+		found := !o.notFound()
+		body.Result.NameFound = &found
+	}
+
+	switch o.Source {
+	case sourceDryRun:
+		message := dryRunMessage
+		body.Status, body.Message = "dry_run", &message
+	case sourceDatabase:
+		age := o.cacheAgeSeconds()
+		body.CacheAgeSeconds = &age
+	case sourceAPI:
+		var message string
+		var age int64
+		body.Message, body.CacheAgeSeconds = &message, &age
+		body.ClientRef = o.ClientRef
+		body.Result.NameMatch = o.Result.NameMatch
+		body.Result.NameMatchScore = o.Result.NameMatchScore
+		if o.Candidates != nil {
+			body.Result.CandidateNames = &xmlNames{Names: o.Candidates}
+		}
+		body.Result.Match, body.Result.MatchScore = o.Match, o.MatchScore
+
+		// Extra provider fields, in a stable order. Values other than
+		// strings are written as JSON, since XML has no natural form for
+		// arbitrary provider data.
+		keys := make([]string, 0, len(o.Result.Fields))
+		for key := range o.Result.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			body.Result.Fields = &xmlFields{}
+		}
+		for _, key := range keys {
+			value, ok := o.Result.Fields[key].(string)
+			if !ok {
+				encoded, err := json.Marshal(o.Result.Fields[key])
+				if err != nil {
+					continue
+				}
+				value = string(encoded)
+			}
+			body.Result.Fields.Fields = append(body.Result.Fields.Fields, xmlField{Name: key, Value: value})
+		}
+	}
+	return body
+}
+
+// apiErrorXML is the XML form of the API error envelope
+type apiErrorXML struct {
+	XMLName xml.Name `xml:"error"`
+	Code    string   `xml:"code"`
+	Message string   `xml:"message"`
+}
+
+// respondWithXML sends an XML response
+func respondWithXML(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(data)
+}
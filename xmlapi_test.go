@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mobile-name-lookup/db"
+)
+
+func TestWantsXML(t *testing.T) {
+	tests := []struct {
+		query  string
+		accept string
+		want   bool
+	}{
+		{"", "", false},
+		{"format=xml", "", true},
+		{"format=xml", "application/json", true},
+		{"format=json", "application/xml", false},
+		{"", "application/xml", true},
+		{"", "text/xml", true},
+		{"", "application/json, application/xml;q=0.9", false},
+		{"", "*/*", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/lookup?"+tt.query, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		if got := wantsXML(req); got != tt.want {
+			t.Errorf("wantsXML(?%s, Accept %q) = %v, want %v", tt.query, tt.accept, got, tt.want)
+		}
+	}
+}
+
+// apiGetXML calls an API route with the test key, asking for XML
+func apiGetXML(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("Accept", "application/xml")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "application/xml") {
+		t.Errorf("Content-Type = %q, want application/xml", got)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestLookupXML(t *testing.T) {
+	provider := &fakeProvider{
+		names:  map[string]string{"9876543210": "Ravi Kumar"},
+		fields: map[string]interface{}{"operator": "Jio", "ported": false, "circle": map[string]interface{}{"code": "MH"}},
+	}
+	server := newTestServer(t, newTestService(db.NewMemoryStore(), provider))
+
+	status, body := apiGetXML(t, server, "/api/v1/lookup?mobile=9876543210")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", status, body)
+	}
+	if !strings.HasPrefix(body, xml.Header) {
+		t.Errorf("body does not start with the XML declaration: %s", body)
+	}
+
+	var got lookupResponseXML
+	if err := xml.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", body, err)
+	}
+	if got.Status != "success" || got.Source != sourceAPI || got.Result.Mobile != "9876543210" || got.Result.MobileLinkedName != "Ravi Kumar" {
+		t.Errorf("response = %+v", got)
+	}
+	if got.Result.NameFound == nil || !*got.Result.NameFound {
+		t.Error("name_found missing or false")
+	}
+	if got.Result.CandidateNames != nil {
+		t.Errorf("candidate_names = %+v, want the element left out", got.Result.CandidateNames)
+	}
+
+	// Extra fields sorted by name, non-strings as JSON
+	want := []xmlField{{"circle", `{"code":"MH"}`}, {"operator", "Jio"}, {"ported", "false"}}
+	if got.Result.Fields == nil || len(got.Result.Fields.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %v", got.Result.Fields, want)
+	}
+	for i, field := range got.Result.Fields.Fields {
+		if field != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, field, want[i])
+		}
+	}
+
+	// Served from the cache the second time, without provider details
+	_, body = apiGetXML(t, server, "/api/v1/lookup?mobile=9876543210")
+	var cached lookupResponseXML
+	if err := xml.Unmarshal([]byte(body), &cached); err != nil {
+		t.Fatal(err)
+	}
+	if cached.Source != sourceDatabase || cached.CacheAgeSeconds == nil || cached.Result.Fields != nil {
+		t.Errorf("cached response = %+v", cached)
+	}
+}
+
+func TestLookupXMLError(t *testing.T) {
+	server := newTestServer(t, newTestService(db.NewMemoryStore(), &fakeProvider{}))
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"mobile=98765", http.StatusBadRequest, errCodeMobileLength},
+		{"mobile=9876543210", http.StatusNotFound, errCodeNotFound},
+	}
+	for _, tt := range tests {
+		status, body := apiGetXML(t, server, "/api/v1/lookup?"+tt.query)
+		var got apiErrorXML
+		if err := xml.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", body, err)
+		}
+		if status != tt.wantStatus || got.Code != tt.wantCode || got.Message == "" {
+			t.Errorf("?%s: got %d %+v, want %d %s", tt.query, status, got, tt.wantStatus, tt.wantCode)
+		}
+	}
+}